@@ -0,0 +1,75 @@
+package loadbalancer
+
+// aliasTable Walker/Vose 别名表，用于 O(1) 的加权随机选择
+// 全部使用 uint64 整数运算，选择结果的分布与权重严格成比例，没有浮点误差，
+// 32位平台上也不会因为 服务器数 * 总权重 溢出。
+// 表有 n 个桶，每个桶的容量为总权重 total：随机数落在第 i 个桶时，
+// 余数小于 threshold[i] 选择 i，否则选择 alias[i]
+type aliasTable struct {
+	threshold []uint64
+	alias     []int
+	total     uint64
+}
+
+// newAliasTable 根据权重构建别名表，weights 中的权重都必须大于0
+func newAliasTable(weights []int) *aliasTable {
+	n := len(weights)
+	t := &aliasTable{
+		threshold: make([]uint64, n),
+		alias:     make([]int, n),
+	}
+	for _, weight := range weights {
+		t.total += uint64(weight)
+	}
+
+	// 权重乘以桶数后，平均每个桶恰好为 total
+	scaled := make([]uint64, n)
+	small := make([]int, 0, n)
+	large := make([]int, 0, n)
+	for i, weight := range weights {
+		scaled[i] = uint64(weight) * uint64(n)
+		if scaled[i] < t.total {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+
+	// 用大桶的余量填满小桶
+	for len(small) > 0 && len(large) > 0 {
+		s := small[len(small)-1]
+		small = small[:len(small)-1]
+		l := large[len(large)-1]
+		large = large[:len(large)-1]
+
+		t.threshold[s] = scaled[s]
+		t.alias[s] = l
+		scaled[l] -= t.total - scaled[s]
+		if scaled[l] < t.total {
+			small = append(small, l)
+		} else {
+			large = append(large, l)
+		}
+	}
+
+	// 剩下的桶都是满的，整数运算下 small 中不会有剩余，这里一并处理以防万一
+	for _, i := range append(small, large...) {
+		t.threshold[i] = t.total
+		t.alias[i] = i
+	}
+	return t
+}
+
+// span 随机数的取值范围 [0, span)
+func (t *aliasTable) span() uint64 {
+	return uint64(len(t.threshold)) * t.total
+}
+
+// index 根据 [0, span) 内的随机数返回选中的下标
+func (t *aliasTable) index(r uint64) int {
+	bucket := int(r / t.total)
+	if r%t.total < t.threshold[bucket] {
+		return bucket
+	}
+	return t.alias[bucket]
+}
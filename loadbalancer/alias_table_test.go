@@ -0,0 +1,55 @@
+package loadbalancer
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+// TestAliasTableExactDistribution 遍历全部随机数，验证每个下标被选中的次数与权重严格成比例
+func TestAliasTableExactDistribution(t *testing.T) {
+	cases := [][]int{
+		{1},
+		{1, 1, 1},
+		{5, 1, 1},
+		{1, 2, 3, 4, 5, 6, 7},
+		{100, 1, 37, 2, 999, 3},
+	}
+	rng := rand.New(rand.NewPCG(1, 2))
+	random := make([]int, 50)
+	for i := range random {
+		random[i] = 1 + rng.IntN(20)
+	}
+	cases = append(cases, random)
+
+	for _, weights := range cases {
+		table := newAliasTable(weights)
+		counts := make([]int, len(weights))
+		for r := uint64(0); r < table.span(); r++ {
+			counts[table.index(r)]++
+		}
+		for i, weight := range weights {
+			// 每个下标命中 weight*n 次，对应概率 weight/total
+			if want := weight * len(weights); counts[i] != want {
+				t.Errorf("权重 %v 下标 %d 应命中%d次，实际: %d", weights, i, want, counts[i])
+			}
+		}
+	}
+}
+
+// TestAliasTableLargeSpan 验证 服务器数 * 总权重 超过32位整数范围时仍能正确选择
+func TestAliasTableLargeSpan(t *testing.T) {
+	weights := make([]int, 10000)
+	for i := range weights {
+		weights[i] = 1000
+	}
+	table := newAliasTable(weights)
+	if span := table.span(); span != 100000000000 {
+		t.Fatalf("span 应为1e11，实际: %d", span)
+	}
+	for i := range weights {
+		r := uint64(i)*table.total + table.total - 1
+		if got := table.index(r); got != i {
+			t.Fatalf("随机数 %d 应选中下标%d，实际: %d", r, i, got)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestGetServerZeroAllocs 验证各算法的 GetServer 在稳定状态下不分配内存
+func TestGetServerZeroAllocs(t *testing.T) {
+	keys := newBenchmarkKeys()
+	for _, n := range []int{3, 100} {
+		for _, c := range newBenchmarkCases(n) {
+			t.Run(fmt.Sprintf("%s/n=%d", c.name, n), func(t *testing.T) {
+				i := 0
+				allocs := testing.AllocsPerRun(1000, func() {
+					server := c.selector.GetServer(keys[i%len(keys)])
+					if c.release != nil {
+						c.release(server)
+					}
+					i++
+				})
+				if allocs != 0 {
+					t.Errorf("GetServer 每次调用应不分配内存，实际: %.1f 次", allocs)
+				}
+			})
+		}
+	}
+}
+
+// TestMaglevGetServerZeroAllocs 验证 Maglev 在有权重为0的服务器和有界负载时也不分配内存
+func TestMaglevGetServerZeroAllocs(t *testing.T) {
+	servers := newBenchmarkServers(10)
+	for i := 0; i < len(servers); i += 2 {
+		servers[i].Weight = 0
+	}
+
+	keys := newBenchmarkKeys()
+	for _, lb := range []*MaglevHashLoadBalancer{
+		NewMaglevHashLoadBalancer(),
+		NewMaglevHashLoadBalancer(WithBoundedLoad(1.25)),
+	} {
+		lb.UpdateServers(servers)
+		i := 0
+		allocs := testing.AllocsPerRun(1000, func() {
+			if server := lb.GetServer(keys[i%len(keys)]); server != nil && lb.balanceFactor > 0 {
+				lb.ReleaseConnection(server)
+			}
+			i++
+		})
+		if allocs != 0 {
+			t.Errorf("balanceFactor=%v 时 GetServer 每次调用应不分配内存，实际: %.1f 次", lb.balanceFactor, allocs)
+		}
+	}
+}
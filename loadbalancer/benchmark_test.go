@@ -0,0 +1,148 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// benchmarkPoolSizes 基准测试使用的服务器数量
+var benchmarkPoolSizes = []int{3, 100, 10000}
+
+// benchmarkCase 基准测试用例
+type benchmarkCase struct {
+	name     string
+	selector Selector
+	// update 设置服务器列表
+	update func(servers []*Server)
+	// release 选择后需要执行的释放操作，可以为nil
+	release func(server *Server)
+}
+
+// newBenchmarkServers 创建 n 台权重为 1,2,3 循环的服务器
+func newBenchmarkServers(n int) []*Server {
+	servers := make([]*Server, n)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("10.%d.%d.%d:8080", i/65536, i/256%256, i%256), Weight: 1 + i%3}
+	}
+	return servers
+}
+
+// newBenchmarkCases 创建包含 n 台服务器的各算法负载均衡器
+func newBenchmarkCases(n int) []benchmarkCase {
+	leastConn := NewLeastConnectionsLoadBalancer(true)
+	p2c := NewPowerOfTwoChoicesLoadBalancer()
+	peakEWMA := NewPeakEWMALoadBalancer(0)
+	jump := NewJumpHashLoadBalancer()
+
+	cases := []benchmarkCase{
+		{name: "Random", selector: NewRandomLoadBalancer()},
+		{name: "RoundRobin", selector: NewRoundRobinLoadBalancer(false)},
+		{name: "WeightedRoundRobin", selector: NewRoundRobinLoadBalancer(true)},
+		{name: "LeastConnections", selector: leastConn, release: leastConn.ReleaseConnection},
+		{name: "PowerOfTwoChoices", selector: p2c, release: p2c.ReleaseConnection},
+		{name: "PeakEWMA", selector: peakEWMA, release: func(server *Server) {
+			peakEWMA.ReleaseConnection(server, 0)
+		}},
+		{name: "Maglev", selector: NewMaglevHashLoadBalancer()},
+		{name: "RingHash", selector: NewRingHashLoadBalancer()},
+		{name: "Rendezvous", selector: NewRendezvousHashLoadBalancer()},
+		{name: "JumpHash", selector: jump, update: func(servers []*Server) {
+			if err := jump.UpdateServers(servers); err != nil {
+				panic(err)
+			}
+		}},
+	}
+
+	servers := newBenchmarkServers(n)
+	for i := range cases {
+		if cases[i].update == nil {
+			cases[i].update = cases[i].selector.(LoadBalancer).UpdateServers
+		}
+		cases[i].update(servers)
+	}
+	return cases
+}
+
+// newBenchmarkKeys 创建基准测试使用的key
+func newBenchmarkKeys() []string {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	return keys
+}
+
+// BenchmarkGetServer 串行调用 GetServer，同时报告每次选择的内存分配
+func BenchmarkGetServer(b *testing.B) {
+	keys := newBenchmarkKeys()
+	for _, n := range benchmarkPoolSizes {
+		for _, c := range newBenchmarkCases(n) {
+			b.Run(fmt.Sprintf("%s/n=%d", c.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					server := c.selector.GetServer(keys[i%len(keys)])
+					if c.release != nil {
+						c.release(server)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGetServerParallel 并发调用 GetServer，配合 -cpu=1,8,64 观察读路径的扩展性
+func BenchmarkGetServerParallel(b *testing.B) {
+	keys := newBenchmarkKeys()
+	for _, n := range benchmarkPoolSizes {
+		for _, c := range newBenchmarkCases(n) {
+			b.Run(fmt.Sprintf("%s/n=%d", c.name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					i := 0
+					for pb.Next() {
+						server := c.selector.GetServer(keys[i%len(keys)])
+						if c.release != nil {
+							c.release(server)
+						}
+						i++
+					}
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkPick 通过 Pick 和 Done 完成一次完整的选择
+func BenchmarkPick(b *testing.B) {
+	keys := newBenchmarkKeys()
+	ctx := context.Background()
+	for _, c := range newBenchmarkCases(100) {
+		b.Run(c.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sel, err := c.selector.Pick(ctx, keys[i%len(keys)])
+				if err != nil {
+					b.Fatal(err)
+				}
+				sel.Done(Result{})
+			}
+		})
+	}
+}
+
+// BenchmarkMaglevBuild 测试 Maglev 查找表的构建耗时，分别使用默认表大小和推荐表大小
+func BenchmarkMaglevBuild(b *testing.B) {
+	for _, n := range benchmarkPoolSizes {
+		servers := newBenchmarkServers(n)
+		for _, size := range []int{lookupTableSize, MaglevTableSizeFor(n)} {
+			lb := NewMaglevHashLoadBalancer(WithTableSize(size))
+			b.Run(fmt.Sprintf("n=%d/M=%d", n, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					lb.buildTable(servers)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package loadbalancer
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrCircuitOpen 所有可用服务器的熔断器都处于打开状态
+var ErrCircuitOpen = errors.New("loadbalancer: all circuit breakers are open")
+
+const (
+	// defaultFailureRatio 默认触发熔断的失败率
+	defaultFailureRatio = 0.5
+	// defaultMinimumRequests 默认统计窗口内触发熔断的最少请求数
+	defaultMinimumRequests = 20
+	// defaultBreakerWindow 默认的统计窗口
+	defaultBreakerWindow = 10 * time.Second
+	// defaultOpenTimeout 默认打开状态的持续时间
+	defaultOpenTimeout = 30 * time.Second
+	// defaultHalfOpenProbes 默认半开状态下允许的并发探测请求数
+	defaultHalfOpenProbes = 1
+)
+
+// CircuitState 熔断器状态
+type CircuitState int32
+
+const (
+	// CircuitClosed 关闭，请求正常通过并统计失败率
+	CircuitClosed CircuitState = iota
+	// CircuitOpen 打开，拒绝所有请求，超时后进入半开状态
+	CircuitOpen
+	// CircuitHalfOpen 半开，只允许有限的探测请求通过，探测全部成功后关闭，任一失败重新打开
+	CircuitHalfOpen
+)
+
+// String 返回状态名称
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "CircuitState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// CircuitBreakerOption 熔断器的配置项
+type CircuitBreakerOption func(*CircuitBreaker)
+
+// WithFailureRatio 设置触发熔断的失败率
+func WithFailureRatio(ratio float64) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.failureRatio = ratio
+	}
+}
+
+// WithMinimumRequests 设置统计窗口内请求数达到多少后才会根据失败率熔断
+func WithMinimumRequests(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.minimumRequests = n
+	}
+}
+
+// WithBreakerWindow 设置关闭状态下统计失败率的窗口，每个窗口结束时清零计数
+func WithBreakerWindow(window time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.window = window
+	}
+}
+
+// WithOpenTimeout 设置打开状态的持续时间，到期后进入半开状态
+func WithOpenTimeout(timeout time.Duration) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.openTimeout = timeout
+	}
+}
+
+// WithHalfOpenProbes 设置半开状态下允许的并发探测请求数，也是关闭熔断器所需的连续成功次数
+func WithHalfOpenProbes(n int) CircuitBreakerOption {
+	return func(cb *CircuitBreaker) {
+		cb.halfOpenProbes = n
+	}
+}
+
+// CircuitBreaker 单台服务器的熔断器
+// 通过 Server.SetCircuitBreaker 关联到服务器后，打开状态下服务器不会被任何负载均衡器选中，
+// 探测名额已满的半开状态下 Pick 会跳过该服务器。熔断器的统计来自 Pick 返回的 Selection.Done，
+// 只使用 GetServer 时不会更新熔断器
+type CircuitBreaker struct {
+	failureRatio    float64
+	minimumRequests int
+	window          time.Duration
+	openTimeout     time.Duration
+	halfOpenProbes  int
+	// now 获取当前时间，测试时可以替换
+	now func() time.Time
+
+	// blocked 当前是否拒绝新请求（打开，或半开且探测名额已满），供选择路径无锁读取
+	blocked atomic.Bool
+	// opened 当前是否处于打开状态，只有它的变化会使快照失效
+	opened atomic.Bool
+
+	mu    sync.Mutex
+	state CircuitState
+	// generation 每次状态变化时递增，用于忽略状态变化前放行的请求的结果
+	generation  uint64
+	windowStart time.Time
+	requests    int
+	failures    int
+	// inFlight 和 successes 为半开状态下的在途探测数和连续成功数
+	inFlight  int
+	successes int
+}
+
+// NewCircuitBreaker 创建熔断器，初始为关闭状态
+func NewCircuitBreaker(opts ...CircuitBreakerOption) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		failureRatio:    defaultFailureRatio,
+		minimumRequests: defaultMinimumRequests,
+		window:          defaultBreakerWindow,
+		openTimeout:     defaultOpenTimeout,
+		halfOpenProbes:  defaultHalfOpenProbes,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(cb)
+	}
+	if cb.halfOpenProbes <= 0 {
+		cb.halfOpenProbes = 1
+	}
+	cb.windowStart = cb.now()
+	return cb
+}
+
+// State 获取熔断器当前状态
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// allow 尝试放行一个请求，返回放行时的状态版本号，请求结束后需以该版本号调用 done
+func (cb *CircuitBreaker) allow() (uint64, bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		return 0, false
+	case CircuitHalfOpen:
+		if cb.inFlight >= cb.halfOpenProbes {
+			return 0, false
+		}
+		cb.inFlight++
+		cb.updateBlocked()
+	}
+	return cb.generation, true
+}
+
+// done 记录 allow 放行的请求的结果，状态已经变化时忽略
+func (cb *CircuitBreaker) done(generation uint64, success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if generation != cb.generation {
+		return
+	}
+
+	switch cb.state {
+	case CircuitClosed:
+		now := cb.now()
+		if now.Sub(cb.windowStart) >= cb.window {
+			cb.windowStart, cb.requests, cb.failures = now, 0, 0
+		}
+		cb.requests++
+		if !success {
+			cb.failures++
+		}
+		if cb.requests >= cb.minimumRequests && float64(cb.failures) >= cb.failureRatio*float64(cb.requests) {
+			cb.open()
+		}
+	case CircuitHalfOpen:
+		cb.inFlight--
+		if !success {
+			cb.open()
+			return
+		}
+		cb.successes++
+		if cb.successes >= cb.halfOpenProbes {
+			cb.setState(CircuitClosed)
+			cb.windowStart, cb.requests, cb.failures = cb.now(), 0, 0
+			return
+		}
+		cb.updateBlocked()
+	}
+}
+
+// open 打开熔断器并在超时后进入半开状态，调用方需持有锁
+func (cb *CircuitBreaker) open() {
+	cb.setState(CircuitOpen)
+	generation := cb.generation
+	time.AfterFunc(cb.openTimeout, func() {
+		cb.mu.Lock()
+		defer cb.mu.Unlock()
+		// 期间状态已经变化（例如被重新打开）时由新的定时器负责
+		if cb.generation == generation {
+			cb.setState(CircuitHalfOpen)
+		}
+	})
+}
+
+// setState 切换状态并重置半开计数，调用方需持有锁
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	cb.state = state
+	cb.generation++
+	cb.inFlight, cb.successes = 0, 0
+	cb.updateBlocked()
+}
+
+// updateBlocked 更新是否拒绝新请求，调用方需持有锁。
+// 半开状态的探测名额随每个请求变化，不使快照失效，由选择时的 allow 和 circuitBlocked 检查；
+// 只有进入或离开打开状态时使快照失效
+func (cb *CircuitBreaker) updateBlocked() {
+	cb.blocked.Store(cb.state == CircuitOpen || (cb.state == CircuitHalfOpen && cb.inFlight >= cb.halfOpenProbes))
+	opened := cb.state == CircuitOpen
+	if cb.opened.Swap(opened) != opened {
+		statusEpoch.Add(1)
+	}
+}
+
+// SetCircuitBreaker 为服务器设置熔断器，为nil表示不使用熔断器。
+// 同一台服务器加入多个负载均衡器时共用同一个熔断器
+func (s *Server) SetCircuitBreaker(cb *CircuitBreaker) {
+	if s.breaker.Swap(cb) != cb {
+		statusEpoch.Add(1)
+	}
+}
+
+// CircuitBreaker 获取服务器的熔断器，没有设置时为nil
+func (s *Server) CircuitBreaker() *CircuitBreaker {
+	return s.breaker.Load()
+}
+
+// circuitBlocked 服务器的熔断器是否拒绝新请求
+func (s *Server) circuitBlocked() bool {
+	cb := s.breaker.Load()
+	return cb != nil && cb.blocked.Load()
+}
+
+// circuitOpen 服务器的熔断器是否处于打开状态
+func (s *Server) circuitOpen() bool {
+	cb := s.breaker.Load()
+	return cb != nil && cb.opened.Load()
+}
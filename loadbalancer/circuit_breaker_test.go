@@ -0,0 +1,233 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// tripBreaker 连续回报失败直到熔断器打开
+func tripBreaker(t *testing.T, cb *CircuitBreaker) {
+	t.Helper()
+	for i := 0; i < cb.minimumRequests; i++ {
+		generation, ok := cb.allow()
+		if !ok {
+			t.Fatal("关闭状态的熔断器不应拒绝请求")
+		}
+		cb.done(generation, false)
+	}
+	if cb.State() != CircuitOpen {
+		t.Fatalf("熔断器状态应为 open，实际: %v", cb.State())
+	}
+}
+
+// waitBreakerState 等待熔断器进入指定状态
+func waitBreakerState(t *testing.T, cb *CircuitBreaker, state CircuitState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for cb.State() != state {
+		if time.Now().After(deadline) {
+			t.Fatalf("熔断器状态应为 %v，实际: %v", state, cb.State())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestCircuitBreakerStateMachine 验证关闭、打开、半开之间的状态转换
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	cb := NewCircuitBreaker(WithMinimumRequests(4), WithFailureRatio(0.5), WithBreakerWindow(time.Minute),
+		WithOpenTimeout(10*time.Millisecond), WithHalfOpenProbes(2))
+
+	// 请求数不足时不熔断
+	for _, success := range []bool{false, false, true} {
+		generation, _ := cb.allow()
+		cb.done(generation, success)
+	}
+	if cb.State() != CircuitClosed {
+		t.Fatalf("请求数不足时状态应为 closed，实际: %v", cb.State())
+	}
+	generation, _ := cb.allow()
+	cb.done(generation, true)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("失败比例达到50%%时状态应为 open，实际: %v", cb.State())
+	}
+	if _, ok := cb.allow(); ok {
+		t.Fatal("打开状态的熔断器不应放行请求")
+	}
+
+	// 半开状态只放行有限的探测请求，探测全部成功后关闭
+	waitBreakerState(t, cb, CircuitHalfOpen)
+	first, ok1 := cb.allow()
+	second, ok2 := cb.allow()
+	if _, ok3 := cb.allow(); !ok1 || !ok2 || ok3 {
+		t.Fatalf("半开状态的放行结果应为 true, true, false，实际: %v, %v, %v", ok1, ok2, ok3)
+	}
+	cb.done(first, true)
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("一次探测成功后状态应为 half-open，实际: %v", cb.State())
+	}
+	cb.done(second, true)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("探测全部成功后状态应为 closed，实际: %v", cb.State())
+	}
+
+	// 半开状态下任一探测失败重新打开
+	tripBreaker(t, cb)
+	waitBreakerState(t, cb, CircuitHalfOpen)
+	generation, _ = cb.allow()
+	cb.done(generation, false)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("探测失败后状态应为 open，实际: %v", cb.State())
+	}
+}
+
+// TestCircuitBreakerIgnoresStaleResults 验证状态变化前放行的请求的结果不会影响新状态
+func TestCircuitBreakerIgnoresStaleResults(t *testing.T) {
+	cb := NewCircuitBreaker(WithMinimumRequests(1), WithOpenTimeout(time.Millisecond))
+	stale, _ := cb.allow()
+	tripBreaker(t, cb)
+	waitBreakerState(t, cb, CircuitHalfOpen)
+
+	cb.done(stale, false)
+	if cb.State() != CircuitHalfOpen {
+		t.Errorf("过期的失败结果不应改变状态，应为 half-open，实际: %v", cb.State())
+	}
+}
+
+// TestBalancersSkipOpenCircuits 验证所有算法都跳过熔断的服务器，全部熔断时 Pick 返回 ErrCircuitOpen
+func TestBalancersSkipOpenCircuits(t *testing.T) {
+	keys := newTestKeys(1000)
+	for _, c := range newSelectorCases() {
+		servers := newTestServers(3)
+		for _, server := range servers {
+			server.SetCircuitBreaker(NewCircuitBreaker(WithOpenTimeout(time.Hour)))
+		}
+		c.update(servers)
+
+		tripBreaker(t, servers[0].CircuitBreaker())
+		for _, key := range keys {
+			server := c.selector.GetServer(key)
+			if server == nil || server == servers[0] {
+				t.Fatalf("%s: key %q 应选中熔断器关闭的服务器，实际: %v", c.name, key, server)
+			}
+			if c.release != nil {
+				c.release(server)
+			}
+		}
+
+		tripBreaker(t, servers[1].CircuitBreaker())
+		tripBreaker(t, servers[2].CircuitBreaker())
+		if server := c.selector.GetServer("key"); server != nil {
+			t.Errorf("%s: 全部熔断时不应选中服务器，实际: %s", c.name, server.Address)
+		}
+		if _, err := c.selector.Pick(context.Background(), "key"); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: Pick 应返回 ErrCircuitOpen，实际: %v", c.name, err)
+		}
+	}
+}
+
+// TestPickLimitsHalfOpenProbes 验证 Pick 在半开状态下只放行有限的探测请求，探测成功后关闭熔断器
+func TestPickLimitsHalfOpenProbes(t *testing.T) {
+	server := &Server{Address: "server1:8080", Weight: 1}
+	cb := NewCircuitBreaker(WithOpenTimeout(time.Millisecond), WithHalfOpenProbes(1))
+	server.SetCircuitBreaker(cb)
+	lb := NewLeastConnectionsLoadBalancer(false)
+	lb.AddServer(server)
+
+	tripBreaker(t, cb)
+	waitBreakerState(t, cb, CircuitHalfOpen)
+
+	probe, err := lb.Pick(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lb.Pick(context.Background(), ""); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("第二次 Pick 应返回 ErrCircuitOpen，实际: %v", err)
+	}
+	if got := lb.connections.load(server); got != 1 {
+		t.Errorf("Pick 被拒绝后连接数应为1，实际: %d", got)
+	}
+
+	probe.Done(Result{})
+	if cb.State() != CircuitClosed {
+		t.Fatalf("探测成功后状态应为 closed，实际: %v", cb.State())
+	}
+	if _, err := lb.Pick(context.Background(), ""); err != nil {
+		t.Errorf("熔断器关闭后 Pick 失败: %v", err)
+	}
+}
+
+// TestHalfOpenProbesKeepSnapshot 验证半开状态下探测名额的占用和释放不会使快照失效，只有进入和离开打开状态会
+func TestHalfOpenProbesKeepSnapshot(t *testing.T) {
+	servers := newTestServers(3)
+	cb := NewCircuitBreaker(WithOpenTimeout(time.Millisecond), WithHalfOpenProbes(2))
+	servers[0].SetCircuitBreaker(cb)
+	lb := NewRandomLoadBalancer()
+	lb.UpdateServers(servers)
+
+	tripBreaker(t, cb)
+	if got := len(lb.loadSnapshot().availableServers); got != 2 {
+		t.Fatalf("熔断器打开时可用服务器数量应为2，实际: %d", got)
+	}
+	waitBreakerState(t, cb, CircuitHalfOpen)
+	snapshot := lb.loadSnapshot()
+	if got := len(snapshot.availableServers); got != 3 {
+		t.Fatalf("熔断器半开时可用服务器数量应为3，实际: %d", got)
+	}
+
+	// 占满探测名额后服务器不可用，但快照保持不变
+	first, _ := cb.allow()
+	second, _ := cb.allow()
+	if servers[0].Available() {
+		t.Error("探测名额占满时服务器不应可用")
+	}
+	cb.done(first, true)
+	if !servers[0].Available() {
+		t.Error("释放探测名额后服务器应可用")
+	}
+	cb.done(second, true)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("探测全部成功后状态应为 closed，实际: %v", cb.State())
+	}
+	if lb.loadSnapshot() != snapshot {
+		t.Error("探测名额的变化不应重建快照")
+	}
+}
+
+// TestPickSkipsFullHalfOpenServer 验证半开服务器的探测名额已满时，Pick 选择其他健康的服务器而不是返回 ErrCircuitOpen
+func TestPickSkipsFullHalfOpenServer(t *testing.T) {
+	peakEWMA := NewPeakEWMALoadBalancer(0)
+	balancers := map[string]LoadBalancer{
+		"Random":             NewRandomLoadBalancer(WithRandomSeed(1)),
+		"PowerOfTwoChoices":  NewPowerOfTwoChoicesLoadBalancer(WithP2CSeed(1)),
+		"RoundRobin":         NewRoundRobinLoadBalancer(false),
+		"WeightedRoundRobin": NewRoundRobinLoadBalancer(true),
+		"LeastConnections":   NewLeastConnectionsLoadBalancer(false),
+		"PeakEWMA":           peakEWMA,
+	}
+	for name, lb := range balancers {
+		probing := &Server{Address: "probing:8080", Weight: 1}
+		healthy := &Server{Address: "healthy:8080", Weight: 1}
+		cb := NewCircuitBreaker(WithOpenTimeout(time.Millisecond), WithHalfOpenProbes(1))
+		probing.SetCircuitBreaker(cb)
+		lb.UpdateServers([]*Server{probing, healthy})
+
+		tripBreaker(t, cb)
+		waitBreakerState(t, cb, CircuitHalfOpen)
+		if _, ok := cb.allow(); !ok {
+			t.Fatalf("%s: 半开状态应放行第一个探测请求", name)
+		}
+
+		for i := 0; i < 1000; i++ {
+			sel, err := lb.Pick(context.Background(), "")
+			if err != nil {
+				t.Fatalf("%s: 第%d次 Pick 失败: %v", name, i+1, err)
+			}
+			if sel.Server != healthy {
+				t.Fatalf("%s: 第%d次 Pick 应选中 %s，实际: %s", name, i+1, healthy.Address, sel.Server.Address)
+			}
+			sel.Done(Result{})
+		}
+	}
+}
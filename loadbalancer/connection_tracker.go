@@ -0,0 +1,105 @@
+package loadbalancer
+
+import (
+	"sync/atomic"
+)
+
+// connectionTracker 跟踪每个服务器的在途连接数，供基于连接数的算法共用
+// 服务器到计数器的映射是不可变的，只在服务器列表变更时整体替换，读路径无需加锁
+type connectionTracker struct {
+	counts atomic.Pointer[map[*Server]*int64]
+	// total 所有服务器的在途连接总数
+	total atomic.Int64
+}
+
+// newConnectionTracker 创建连接计数器
+func newConnectionTracker() *connectionTracker {
+	t := &connectionTracker{}
+	counts := make(map[*Server]*int64)
+	t.counts.Store(&counts)
+	return t
+}
+
+// sync 根据服务器列表同步连接计数，保留仍在列表中的服务器的计数。
+// 调用方需保证 sync 之间不会并发执行
+func (t *connectionTracker) sync(servers []*Server) {
+	old := *t.counts.Load()
+	counts := make(map[*Server]*int64, len(servers))
+	for _, server := range servers {
+		connPtr := old[server]
+		if connPtr == nil {
+			connPtr = new(int64)
+		}
+		counts[server] = connPtr
+	}
+	// 已移除服务器的在途连接不再计入总数
+	for server, connPtr := range old {
+		if _, ok := counts[server]; !ok {
+			t.total.Add(-atomic.LoadInt64(connPtr))
+		}
+	}
+	t.counts.Store(&counts)
+}
+
+// counter 获取服务器的计数器，服务器不在列表中时返回nil
+func (t *connectionTracker) counter(server *Server) *int64 {
+	return (*t.counts.Load())[server]
+}
+
+// load 获取服务器当前的连接数
+func (t *connectionTracker) load(server *Server) int64 {
+	if connPtr := t.counter(server); connPtr != nil {
+		return atomic.LoadInt64(connPtr)
+	}
+	return 0
+}
+
+// loadTotal 获取所有服务器的在途连接总数
+func (t *connectionTracker) loadTotal() int64 {
+	return t.total.Load()
+}
+
+// acquire 增加服务器的连接数
+func (t *connectionTracker) acquire(server *Server) {
+	if connPtr := t.counter(server); connPtr != nil {
+		atomic.AddInt64(connPtr, 1)
+		t.total.Add(1)
+	}
+	// 同时更新Server结构体中的CurrentConnections字段，方便外部查看
+	atomic.AddInt32(&server.CurrentConnections, 1)
+}
+
+// release 减少服务器的连接数，不会减到0以下
+func (t *connectionTracker) release(server *Server) {
+	if server == nil {
+		return
+	}
+
+	if connPtr := t.counter(server); connPtr != nil && decrementIfPositive64(connPtr) {
+		t.total.Add(-1)
+	}
+	decrementIfPositive32(&server.CurrentConnections)
+}
+
+// decrementIfPositive64 在值大于0时原子地减1，返回是否减少
+func decrementIfPositive64(addr *int64) bool {
+	for {
+		v := atomic.LoadInt64(addr)
+		if v <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(addr, v, v-1) {
+			return true
+		}
+	}
+}
+
+// decrementIfPositive32 在值大于0时原子地减1
+func decrementIfPositive32(addr *int32) {
+	for {
+		v := atomic.LoadInt32(addr)
+		if v <= 0 || atomic.CompareAndSwapInt32(addr, v, v-1) {
+			return
+		}
+	}
+}
@@ -1,247 +1,291 @@
 package loadbalancer
 
 import (
-	"sync"
-
-	"github.com/spaolacci/murmur3"
-	"github.com/zeebo/xxh3"
+	"context"
+	"math"
+	"sync/atomic"
 )
 
 const (
-	// 查找表大小，应该是质数
+	// 默认查找表大小，应该是质数
 	lookupTableSize = 65537
+	// maglevEntriesPerBackend 推荐的查找表大小与服务器数量之比，论文建议 M 远大于 N
+	maglevEntriesPerBackend = 100
 )
 
-// MaglevHashLoadBalancer Maglev一致性哈希负载均衡器
-type MaglevHashLoadBalancer struct {
-	*BaseLoadBalancer
-	lookupTable []int
-	tableSize   int
-	mu          sync.RWMutex
+// MaglevOption Maglev一致性哈希负载均衡器的配置项
+type MaglevOption func(*MaglevHashLoadBalancer)
+
+// WithTableSize 设置查找表大小，Maglev 要求表大小为质数，size 不是质数时向上取到下一个质数，
+// 小于2时忽略该配置。表越大负载越均衡，但占用内存和构建时间也越多，
+// 可以使用 MaglevTableSizeFor 根据服务器数量计算
+func WithTableSize(size int) MaglevOption {
+	return func(lb *MaglevHashLoadBalancer) {
+		if size >= 2 {
+			lb.tableSize = nextPrime(size)
+		}
+	}
 }
 
-// NewMaglevHashLoadBalancer 创建Maglev一致性哈希负载均衡器
-func NewMaglevHashLoadBalancer() *MaglevHashLoadBalancer {
-	return &MaglevHashLoadBalancer{
-		BaseLoadBalancer: NewBaseLoadBalancer(),
-		tableSize:        lookupTableSize, // 使用质数作为表大小
-		lookupTable:      make([]int, lookupTableSize),
+// WithKeyHash 设置计算key在查找表中位置的哈希函数，默认为 murmur3
+func WithKeyHash(fn HashFunc) MaglevOption {
+	return func(lb *MaglevHashLoadBalancer) {
+		lb.keyHash = fn
+	}
+}
+
+// WithPermutationHashes 设置计算服务器排列 offset 和 skip 的两个哈希函数，默认为 murmur3 和 xxh3，
+// 两者应相互独立
+func WithPermutationHashes(offsetHash, skipHash HashFunc) MaglevOption {
+	return func(lb *MaglevHashLoadBalancer) {
+		lb.offsetHash = offsetHash
+		lb.skipHash = skipHash
 	}
 }
 
-// AddServer 添加服务器
-func (lb *MaglevHashLoadBalancer) AddServer(server *Server) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
+// MaglevTableSizeFor 根据服务器数量返回推荐的查找表大小，即大于 100 * backends 的最小质数
+func MaglevTableSizeFor(backends int) int {
+	return nextPrime(maglevEntriesPerBackend*backends + 1)
+}
 
-	// 确保EffectiveWeight初始化
-	if server.EffectiveWeight == 0 {
-		server.EffectiveWeight = server.Weight
+// nextPrime 返回不小于n的最小质数
+func nextPrime(n int) int {
+	for !isPrime(n) {
+		n++
 	}
+	return n
+}
 
-	lb.Servers = append(lb.Servers, server)
-	lb.updateLookupTable()
+// isPrime 判断n是否为质数
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
 }
 
-// RemoveServer 移除服务器
-func (lb *MaglevHashLoadBalancer) RemoveServer(server *Server) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-	for i, s := range lb.Servers {
-		if s == server {
-			lb.Servers = append(lb.Servers[:i], lb.Servers[i+1:]...)
-			break
+// WithBoundedLoad 启用有界负载一致性哈希（Mirrokni 等），c 为负载均衡因子，必须大于1。
+// 每台服务器的在途请求容量为 ceil(c * 平均负载)（按权重折算），key 命中已满的服务器时
+// 沿查找表继续寻找下一个候选服务器。启用后 GetServer 会增加在途请求数，
+// 请求结束后需调用 ReleaseConnection 或通过 Pick 返回的句柄释放
+func WithBoundedLoad(c float64) MaglevOption {
+	return func(lb *MaglevHashLoadBalancer) {
+		if c > 1 {
+			lb.balanceFactor = c
 		}
 	}
-	lb.updateLookupTable()
 }
 
-// permutation 计算服务器在查找表中的位置
-func (lb *MaglevHashLoadBalancer) permutation(serverIndex int) []int {
-	// 使用服务器地址和索引结合计算哈希值，增加多样性
-	server := lb.Servers[serverIndex]
-	uniqueKey := server.Address + ":" + string(rune(serverIndex))
+// MaglevHashLoadBalancer Maglev一致性哈希负载均衡器
+// 查找表在服务器变更时于旁路构建，完成后通过原子指针整体替换，
+// 构建期间 GetServer 继续使用旧表，不会被阻塞
+type MaglevHashLoadBalancer struct {
+	*BaseLoadBalancer
+	table      atomic.Pointer[maglevTable]
+	tableSize  int
+	keyHash    HashFunc
+	offsetHash HashFunc
+	skipHash   HashFunc
+	// balanceFactor 有界负载的均衡因子，为0表示不启用
+	balanceFactor float64
+	connections   *connectionTracker
+}
 
-	// 优化哈希种子计算
-	offset := murmur3.Sum64([]byte(uniqueKey)) % uint64(lb.tableSize)
-	skip := xxh3.Hash([]byte(uniqueKey))%uint64(lb.tableSize-1) + 1 // 确保skip至少为1且不超过tableSize
+// maglevTable 构建完成后不可变的查找表
+type maglevTable struct {
+	// lookup 查找表，每个表项保存占据该位置的服务器，nil表示空
+	lookup []*Server
+	// availableServers 构建时可用的服务器
+	availableServers []*Server
+}
 
-	weight := server.Weight
-	if weight <= 0 {
-		weight = 1 // 确保至少有权重1，避免除零错误
+// NewMaglevHashLoadBalancer 创建Maglev一致性哈希负载均衡器
+func NewMaglevHashLoadBalancer(opts ...MaglevOption) *MaglevHashLoadBalancer {
+	lb := &MaglevHashLoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+		tableSize:        lookupTableSize, // 使用质数作为表大小
+		keyHash:          Murmur3Hash,
+		offsetHash:       Murmur3Hash,
+		skipHash:         XXH3Hash,
+		connections:      newConnectionTracker(),
 	}
-
-	// 考虑权重因素，影响排列的生成
-	perm := make([]int, lb.tableSize)
-	for i := 0; i < lb.tableSize; i++ {
-		// 根据权重调整偏移量，权重大的服务器有更多机会被选中
-		adjustedOffset := (offset + uint64(i*weight)) % uint64(lb.tableSize)
-		perm[i] = int((adjustedOffset + uint64(i)*skip) % uint64(lb.tableSize))
+	for _, opt := range opts {
+		opt(lb)
 	}
-	return perm
+	lb.table.Store(&maglevTable{lookup: make([]*Server, lb.tableSize)})
+	lb.onChange = lb.syncServers
+	return lb
 }
 
-// updateLookupTable 更新查找表
-func (lb *MaglevHashLoadBalancer) updateLookupTable() {
-	if len(lb.Servers) == 0 {
-		// 如果没有服务器，清空查找表
-		for i := range lb.lookupTable {
-			lb.lookupTable[i] = -1
-		}
-		return
-	}
+// syncServers 服务器列表变更后重建查找表并同步在途请求计数
+func (lb *MaglevHashLoadBalancer) syncServers(servers []*Server) {
+	lb.connections.sync(servers)
+	lb.table.Store(lb.buildTable(servers))
+}
 
-	// 筛选可用的服务器
-	availableServers := make([]*Server, 0)
-	serverIndexMap := make(map[*Server]int)
-	weightSum := 0
+// maglevEntry 构建查找表时每台服务器的排列状态
+type maglevEntry struct {
+	server *Server
+	// 排列 permutation[j] = (offset + j*skip) % M 中下一个要尝试的位置
+	next uint64
+	skip uint64
+	// weight 和 target 用于按权重分配填表的轮次
+	weight int64
+	target int64
+}
 
-	for i, server := range lb.Servers {
-		if server.Weight > 0 { // 只考虑权重大于0的服务器为可用
-			availableServers = append(availableServers, server)
-			serverIndexMap[server] = i
-			weightSum += server.Weight
-		}
-	}
+// permutation 计算服务器排列的 offset 和 skip
+// 排列只由服务器的稳定标识决定，与服务器在列表中的位置无关，
+// 因此移除某台服务器不会影响其他服务器的排列
+func (lb *MaglevHashLoadBalancer) permutation(server *Server) (offset, skip uint64) {
+	identity := []byte(server.Identity())
+	offset = lb.offsetHash(identity) % uint64(lb.tableSize)
+	skip = lb.skipHash(identity)%uint64(lb.tableSize-1) + 1 // 确保skip至少为1且不超过tableSize
+	return offset, skip
+}
 
-	// 如果没有可用服务器，清空查找表
-	if len(availableServers) == 0 {
-		for i := range lb.lookupTable {
-			lb.lookupTable[i] = -1
-		}
-		return
+// buildTable 按 Maglev 论文的方式构建新的查找表：
+// 服务器轮流沿各自的排列占据第一个空位，直到整张表填满。
+// 权重通过轮次体现：权重最大的服务器每轮都参与，权重为其 1/k 的服务器每 k 轮参与一次，
+// 因此每台服务器占据的表项数与权重成正比
+func (lb *MaglevHashLoadBalancer) buildTable(servers []*Server) *maglevTable {
+	table := &maglevTable{
+		lookup: make([]*Server, lb.tableSize),
 	}
 
-	// 初始化查找表
-	for i := range lb.lookupTable {
-		lb.lookupTable[i] = -1
+	// 只考虑权重大于0的服务器为可用
+	entries := make([]maglevEntry, 0, len(servers))
+	var maxWeight int64
+	for _, server := range servers {
+		if server.Weight <= 0 {
+			continue
+		}
+		offset, skip := lb.permutation(server)
+		entries = append(entries, maglevEntry{
+			server: server,
+			next:   offset,
+			skip:   skip,
+			weight: int64(server.Weight),
+		})
+		if int64(server.Weight) > maxWeight {
+			maxWeight = int64(server.Weight)
+		}
+		table.availableServers = append(table.availableServers, server)
 	}
 
-	// 计算每个可用服务器的排列
-	perms := make([][]int, len(availableServers))
-	for i, server := range availableServers {
-		// 使用原始索引计算排列
-		origIndex := serverIndexMap[server]
-		perms[i] = lb.permutation(origIndex)
+	// 如果没有可用服务器，保持查找表为空
+	if len(entries) == 0 {
+		return table
 	}
 
-	// 填充查找表 - 考虑权重因素
-	next := make([]int, len(availableServers))
+	tableSize := uint64(lb.tableSize)
 	filled := 0
-
-	// 确保表至少有75%填满
-	for filled < lb.tableSize*3/4 {
-		// 找到所有服务器中下一个未使用的位置
-		minPos := lb.tableSize
-		for i := range availableServers {
-			if next[i] < lb.tableSize && perms[i][next[i]] < minPos {
-				minPos = perms[i][next[i]]
+	for iteration := int64(1); filled < lb.tableSize; iteration++ {
+		for i := range entries {
+			if filled == lb.tableSize {
+				break
 			}
-		}
-		if minPos == lb.tableSize {
-			break // 所有位置都已填满
-		}
-
-		// 按权重比例选择服务器
-		selectedIndex := -1
 
-		// 首先尝试按权重选择第一个到达该位置的服务器
-		candidates := make([]int, 0)
-		for i := range availableServers {
-			if next[i] < lb.tableSize && perms[i][next[i]] == minPos {
-				candidates = append(candidates, i)
+			entry := &entries[i]
+			if iteration*entry.weight < entry.target {
+				continue
 			}
-		}
+			entry.target += maxWeight
 
-		if len(candidates) > 0 {
-			// 如果有多个候选服务器，根据权重选择
-			if len(candidates) > 1 {
-				// 权重大的服务器优先
-				maxWeight := 0
-				for _, idx := range candidates {
-					if availableServers[idx].Weight > maxWeight {
-						maxWeight = availableServers[idx].Weight
-						selectedIndex = idx
-					}
-				}
-			} else {
-				selectedIndex = candidates[0]
+			// 沿排列找到第一个空位
+			for table.lookup[entry.next] != nil {
+				entry.next = (entry.next + entry.skip) % tableSize
 			}
-
-			// 使用原始索引填充查找表
-			lb.lookupTable[minPos] = serverIndexMap[availableServers[selectedIndex]]
-			next[selectedIndex]++
+			table.lookup[entry.next] = entry.server
+			entry.next = (entry.next + entry.skip) % tableSize
 			filled++
 		}
 	}
-
-	// 确保表完全填满
-	for i := range lb.lookupTable {
-		if lb.lookupTable[i] == -1 {
-			// 如果某个位置未分配，随机选择一个可用服务器
-			// 但倾向于选择权重更高的服务器
-			totalWeight := 0
-			for _, server := range availableServers {
-				totalWeight += server.Weight
-			}
-
-			if totalWeight > 0 {
-				// 按权重选择
-				randomWeight := int(murmur3.Sum32([]byte(string(rune(i))))) % totalWeight
-				cumulativeWeight := 0
-				for _, server := range availableServers {
-					cumulativeWeight += server.Weight
-					if randomWeight < cumulativeWeight {
-						lb.lookupTable[i] = serverIndexMap[server]
-						break
-					}
-				}
-			} else {
-				// 权重和为0，直接选择第一个服务器
-				lb.lookupTable[i] = serverIndexMap[availableServers[0]]
-			}
-		}
-	}
+	return table
 }
 
-// GetServer 根据key获取服务器
+// GetServer 根据key获取服务器，不需要加锁
 func (lb *MaglevHashLoadBalancer) GetServer(key string) *Server {
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
-
-	if len(lb.Servers) == 0 {
+	table := lb.table.Load()
+	if len(table.availableServers) == 0 {
 		return nil
 	}
 
 	// 使用key计算哈希值
-	hash := murmur3.Sum64([]byte(key))
+	hash := lb.keyHash(keyBytes(key))
 	index := int(hash % uint64(lb.tableSize))
-	serverIndex := lb.lookupTable[index]
+	if lb.balanceFactor > 0 {
+		return lb.getBoundedServer(table, index)
+	}
+	server := table.lookup[index]
 
-	if serverIndex == -1 || serverIndex >= len(lb.Servers) || lb.Servers[serverIndex].Weight <= 0 {
+	if server == nil || !server.Available() {
 		// 如果查找表中没有对应的服务器，或者服务器不可用，
 		// 尝试查找表中的其他位置
 		for offset := 1; offset < 20; offset++ {
 			newIndex := (index + offset) % lb.tableSize
-			serverIndex = lb.lookupTable[newIndex]
-			if serverIndex >= 0 && serverIndex < len(lb.Servers) && lb.Servers[serverIndex].Weight > 0 {
-				return lb.Servers[serverIndex]
+			server = table.lookup[newIndex]
+			if server != nil && server.Available() {
+				return server
 			}
 		}
 
 		// 如果仍未找到，回退到简单哈希
-		availableServers := make([]*Server, 0)
-		for _, server := range lb.Servers {
-			if server.Weight > 0 {
-				availableServers = append(availableServers, server)
+		count := len(table.availableServers)
+		start := int(hash % uint64(count))
+		for i := 0; i < count; i++ {
+			server = table.availableServers[(start+i)%count]
+			if server.Available() {
+				return server
 			}
 		}
+		return nil
+	}
 
-		if len(availableServers) > 0 {
-			return availableServers[int(hash%uint64(len(availableServers)))]
-		}
+	return server
+}
+
+// getBoundedServer 从查找表的 index 位置开始寻找未超过容量的服务器并增加其在途请求数。
+// 平均负载按当前可用服务器的权重折算，不可用的服务器不分摊容量
+func (lb *MaglevHashLoadBalancer) getBoundedServer(table *maglevTable, index int) *Server {
+	totalWeight := lb.loadSnapshot().totalWeight
+	if totalWeight == 0 {
 		return nil
 	}
 
-	return lb.Servers[serverIndex]
+	// 计入本次请求后的平均负载
+	totalLoad := float64(lb.connections.loadTotal() + 1)
+	for offset := 0; offset < lb.tableSize; offset++ {
+		server := table.lookup[(index+offset)%lb.tableSize]
+		if server == nil || !server.Available() {
+			continue
+		}
+
+		capacity := math.Ceil(lb.balanceFactor * totalLoad * float64(server.Weight) / float64(totalWeight))
+		if float64(lb.connections.load(server)+1) <= capacity {
+			lb.connections.acquire(server)
+			return server
+		}
+	}
+	return nil
+}
+
+// ReleaseConnection 释放有界负载模式下的在途请求
+func (lb *MaglevHashLoadBalancer) ReleaseConnection(server *Server) {
+	lb.connections.release(server)
+}
+
+// Pick 根据key选择服务器并返回选择句柄，有界负载模式下句柄的 Done 会释放在途请求
+func (lb *MaglevHashLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	if lb.balanceFactor > 0 {
+		return lb.pick(ctx, key, lb.GetServer, func(server *Server, _ Result) {
+			lb.ReleaseConnection(server)
+		})
+	}
+	return lb.pick(ctx, key, lb.GetServer, nil)
 }
@@ -0,0 +1,285 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestMaglevBoundedLoad(t *testing.T) {
+	const c = 1.25
+	lb := NewMaglevHashLoadBalancer(WithBoundedLoad(c))
+	servers := make([]*Server, 4)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("Server-%d", i), Weight: 1}
+		lb.AddServer(servers[i])
+	}
+
+	// 同一个热点key的请求不会全部压到同一台服务器上
+	const requests = 100
+	selections := make([]*Selection, 0, requests)
+	for i := 0; i < requests; i++ {
+		sel, err := lb.Pick(context.Background(), "hot-key")
+		if err != nil {
+			t.Fatalf("Pick 失败: %v", err)
+		}
+		selections = append(selections, sel)
+	}
+
+	capacity := int64(math.Ceil(c * requests / float64(len(servers))))
+	for _, server := range servers {
+		if load := lb.connections.load(server); load > capacity {
+			t.Errorf("%s 的在途请求数 %d 超过容量 %d", server.Address, load, capacity)
+		}
+	}
+
+	// 释放后热点key回到原来的服务器
+	for _, sel := range selections {
+		sel.Done(Result{})
+	}
+	if total := lb.connections.loadTotal(); total != 0 {
+		t.Fatalf("释放后在途请求总数应为0，实际: %d", total)
+	}
+	if server := lb.GetServer("hot-key"); server != selections[0].Server {
+		t.Errorf("释放后应回到首选服务器 %s，实际: %s", selections[0].Server.Address, server.Address)
+	}
+}
+
+// TestMaglevBoundedLoadSkipsUnavailable 验证不可用的服务器不分摊容量，有界负载仍能选出可用服务器
+func TestMaglevBoundedLoadSkipsUnavailable(t *testing.T) {
+	lb := NewMaglevHashLoadBalancer(WithBoundedLoad(1.25))
+	servers := []*Server{
+		{Address: "Server-0", Weight: 1},
+		{Address: "Server-1", Weight: 1},
+	}
+	lb.UpdateServers(servers)
+	servers[1].SetStatus(StatusDisabled)
+
+	for i := 0; i < 50; i++ {
+		sel, err := lb.Pick(context.Background(), fmt.Sprintf("key%d", i))
+		if err != nil {
+			t.Fatalf("第%d次 Pick 失败: %v", i+1, err)
+		}
+		if sel.Server != servers[0] {
+			t.Fatalf("第%d次 Pick 应选中 %s，实际: %s", i+1, servers[0].Address, sel.Server.Address)
+		}
+	}
+}
+
+// newMaglevTestServers 创建权重为 1,2,3 循环的测试服务器
+func newMaglevTestServers(n int) []*Server {
+	servers := make([]*Server, n)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("10.0.0.%d:8080", i), Weight: 1 + i%3}
+	}
+	return servers
+}
+
+// maglevAssignments 记录一批key映射到的服务器
+func maglevAssignments(lb *MaglevHashLoadBalancer, keyCount int) []*Server {
+	assignments := make([]*Server, keyCount)
+	for i := range assignments {
+		assignments[i] = lb.GetServer(fmt.Sprintf("key%d", i))
+	}
+	return assignments
+}
+
+func TestMaglevBackendShare(t *testing.T) {
+	for _, n := range []int{3, 10, 50} {
+		lb := NewMaglevHashLoadBalancer()
+		servers := newMaglevTestServers(n)
+		totalWeight := 0
+		for _, server := range servers {
+			lb.AddServer(server)
+			totalWeight += server.Weight
+		}
+
+		// 查找表中每台服务器占据的表项数与权重成正比
+		entries := make(map[*Server]int)
+		for _, server := range lb.table.Load().lookup {
+			entries[server]++
+		}
+		for _, server := range servers {
+			expected := float64(lb.tableSize) * float64(server.Weight) / float64(totalWeight)
+			if math.Abs(float64(entries[server])-expected) > float64(n) {
+				t.Errorf("n=%d %s 应占据约%.0f个表项，实际: %d", n, server.Address, expected, entries[server])
+			}
+		}
+
+		// key 的分布与权重比例一致
+		const keyCount = 100000
+		counts := make(map[*Server]int)
+		for _, server := range maglevAssignments(lb, keyCount) {
+			counts[server]++
+		}
+		for _, server := range servers {
+			expected := float64(server.Weight) / float64(totalWeight)
+			actual := float64(counts[server]) / keyCount
+			if math.Abs(actual-expected) > 0.01 {
+				t.Errorf("n=%d %s 的占比应约为%.4f，实际: %.4f", n, server.Address, expected, actual)
+			}
+		}
+	}
+}
+
+func TestMaglevDisruption(t *testing.T) {
+	const keyCount = 100000
+	// 论文中 M=65537 时服务器变更带来的额外扰动很小，这里允许比理想值多 2%
+	const tolerance = 0.02
+
+	for _, n := range []int{3, 10, 50} {
+		lb := NewMaglevHashLoadBalancer()
+		servers := newMaglevTestServers(n)
+		totalWeight := 0
+		for _, server := range servers {
+			lb.AddServer(server)
+			totalWeight += server.Weight
+		}
+		before := maglevAssignments(lb, keyCount)
+
+		// 添加服务器：理想情况下只有新服务器的份额被重新映射
+		added := &Server{Address: "10.0.1.0:8080", Weight: 2}
+		lb.AddServer(added)
+		after := maglevAssignments(lb, keyCount)
+		remapped := 0
+		for i := range before {
+			if before[i] != after[i] {
+				remapped++
+			}
+		}
+		ideal := float64(added.Weight) / float64(totalWeight+added.Weight)
+		if actual := float64(remapped) / keyCount; actual > ideal+tolerance {
+			t.Errorf("n=%d 添加服务器后重新映射的比例为%.4f，理想值: %.4f", n, actual, ideal)
+		}
+
+		// 移除服务器：理想情况下只有被移除服务器上的key被重新映射
+		lb.RemoveServerByPointer(added)
+		removed := servers[n-1]
+		lb.RemoveServerByPointer(removed)
+		after = maglevAssignments(lb, keyCount)
+		remapped = 0
+		for i := range before {
+			if before[i] != removed && before[i] != after[i] {
+				remapped++
+			}
+		}
+		if actual := float64(remapped) / keyCount; actual > tolerance {
+			t.Errorf("n=%d 移除服务器后其他服务器上被重新映射的比例为%.4f", n, actual)
+		}
+	}
+}
+
+func TestMaglevStableIdentity(t *testing.T) {
+	newServers := func(addressB string) []*Server {
+		return []*Server{
+			{Address: "10.0.0.1:8080", Weight: 1},
+			{ID: "shard-b", Address: addressB, Weight: 1},
+			{Address: "10.0.0.3:8080", Weight: 1},
+		}
+	}
+
+	lb := NewMaglevHashLoadBalancer()
+	servers := newServers("10.0.0.2:8080")
+	for _, server := range servers {
+		lb.AddServer(server)
+	}
+
+	// 移除中间的服务器时，其他服务器的排列不变，只有极少量额外的key被重新映射
+	const keyCount = 100000
+	before := maglevAssignments(lb, keyCount)
+	lb.RemoveServerByPointer(servers[1])
+	after := maglevAssignments(lb, keyCount)
+	remapped := 0
+	for i := range before {
+		if before[i] != servers[1] && before[i] != after[i] {
+			remapped++
+		}
+	}
+	if actual := float64(remapped) / keyCount; actual > 0.02 {
+		t.Errorf("移除中间的服务器后其他服务器上被重新映射的比例为%.4f", actual)
+	}
+
+	// 排列只与标识有关：ID相同、地址不同的服务器得到完全相同的查找表
+	other := NewMaglevHashLoadBalancer()
+	for _, server := range newServers("10.0.0.4:8080") {
+		other.AddServer(server)
+	}
+	lb.AddServer(servers[1])
+	lb.RemoveServerByPointer(servers[2])
+	lb.AddServer(servers[2])
+	for i := range lb.table.Load().lookup {
+		if lb.table.Load().lookup[i].Identity() != other.table.Load().lookup[i].Identity() {
+			t.Fatalf("第%d个表项应为 %s，实际: %s", i, other.table.Load().lookup[i].Identity(), lb.table.Load().lookup[i].Identity())
+		}
+	}
+}
+
+func TestMaglevTableSizeOptions(t *testing.T) {
+	if size := MaglevTableSizeFor(3); size != 307 {
+		t.Errorf("3台服务器的推荐表大小应为307，实际: %d", size)
+	}
+	if size := MaglevTableSizeFor(5000); size != 500009 {
+		t.Errorf("5000台服务器的推荐表大小应为500009，实际: %d", size)
+	}
+
+	// 非质数的表大小向上取到下一个质数，小于2时忽略
+	if size := NewMaglevHashLoadBalancer(WithTableSize(65536)).tableSize; size != 65537 {
+		t.Errorf("表大小65536应取为65537，实际: %d", size)
+	}
+	if size := NewMaglevHashLoadBalancer(WithTableSize(0)).tableSize; size != lookupTableSize {
+		t.Errorf("表大小0应被忽略，实际: %d", size)
+	}
+
+	lb := NewMaglevHashLoadBalancer(
+		WithTableSize(MaglevTableSizeFor(3)),
+		WithKeyHash(XXH3Hash),
+		WithPermutationHashes(XXH3Hash, Murmur3Hash),
+	)
+	servers := newMaglevTestServers(3)
+	for _, server := range servers {
+		lb.AddServer(server)
+	}
+	if len(lb.table.Load().lookup) != 307 {
+		t.Fatalf("查找表大小应为307，实际: %d", len(lb.table.Load().lookup))
+	}
+	for i, server := range lb.table.Load().lookup {
+		if server == nil {
+			t.Fatalf("第%d个表项未填充", i)
+		}
+	}
+}
+
+func TestMaglevUpdateServers(t *testing.T) {
+	lb := NewMaglevHashLoadBalancer()
+	for _, server := range newMaglevTestServers(3) {
+		lb.AddServer(server)
+	}
+	oldTable := lb.table.Load()
+
+	// 批量替换成员后一次性发布新表，旧表保持不变
+	servers := newMaglevTestServers(10)[5:]
+	lb.UpdateServers(servers)
+	if lb.GetServerCount() != len(servers) {
+		t.Fatalf("服务器数量应为%d，实际: %d", len(servers), lb.GetServerCount())
+	}
+
+	table := lb.table.Load()
+	if table == oldTable {
+		t.Fatal("UpdateServers 后应发布新的查找表")
+	}
+	members := make(map[*Server]bool)
+	for _, server := range servers {
+		members[server] = true
+	}
+	for i, server := range table.lookup {
+		if !members[server] {
+			t.Fatalf("第%d个表项不属于新的服务器列表: %v", i, server)
+		}
+	}
+	for _, server := range oldTable.lookup {
+		if members[server] {
+			t.Fatal("旧的查找表不应被修改")
+		}
+	}
+}
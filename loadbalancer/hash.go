@@ -0,0 +1,27 @@
+package loadbalancer
+
+import (
+	"unsafe"
+
+	"github.com/spaolacci/murmur3"
+	"github.com/zeebo/xxh3"
+)
+
+// HashFunc 64位哈希函数，用于一致性哈希类算法的可插拔哈希
+type HashFunc func(data []byte) uint64
+
+// keyBytes 以零拷贝的方式将key转换为字节切片，避免每次选择时分配内存。
+// 返回的切片与key共享底层内存，调用方不能修改，HashFunc 也不能持有它
+func keyBytes(key string) []byte {
+	return unsafe.Slice(unsafe.StringData(key), len(key))
+}
+
+// Murmur3Hash murmur3 64位哈希
+func Murmur3Hash(data []byte) uint64 {
+	return murmur3.Sum64(data)
+}
+
+// XXH3Hash xxh3 64位哈希
+func XXH3Hash(data []byte) uint64 {
+	return xxh3.Hash(data)
+}
@@ -0,0 +1,343 @@
+package loadbalancer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultHealthCheckInterval 默认的探测间隔
+	defaultHealthCheckInterval = 5 * time.Second
+	// defaultHealthCheckTimeout 默认的单次探测超时
+	defaultHealthCheckTimeout = time.Second
+	// defaultHealthyThreshold 默认连续成功多少次后标记为健康
+	defaultHealthyThreshold = 2
+	// defaultUnhealthyThreshold 默认连续失败多少次后标记为不健康
+	defaultUnhealthyThreshold = 3
+	// httpProbeMaxBody HTTP 探测最多读取的响应体字节数
+	httpProbeMaxBody = 64 << 10
+)
+
+// Probe 对单台服务器做一次健康探测，返回nil表示健康。
+// ctx 带有探测超时，实现应在 ctx 结束时尽快返回
+type Probe func(ctx context.Context, server *Server) error
+
+// TCPProbe 通过建立 TCP 连接探测服务器，连接成功即认为健康
+func TCPProbe() Probe {
+	var dialer net.Dialer
+	return func(ctx context.Context, server *Server) error {
+		conn, err := dialer.DialContext(ctx, "tcp", server.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// HTTPProbeOption HTTP 探测的配置项
+type HTTPProbeOption func(*httpProbe)
+
+// WithExpectedStatus 设置认为健康的响应状态码，默认为任意 2xx
+func WithExpectedStatus(codes ...int) HTTPProbeOption {
+	return func(p *httpProbe) {
+		p.expectedStatus = codes
+	}
+}
+
+// WithExpectedBody 要求响应体包含指定内容
+func WithExpectedBody(substr string) HTTPProbeOption {
+	return func(p *httpProbe) {
+		p.expectedBody = substr
+	}
+}
+
+// WithHTTPScheme 设置探测使用的协议，默认为 http
+func WithHTTPScheme(scheme string) HTTPProbeOption {
+	return func(p *httpProbe) {
+		p.scheme = scheme
+	}
+}
+
+// WithHTTPClient 设置探测使用的 HTTP 客户端，默认为 http.DefaultClient
+func WithHTTPClient(client *http.Client) HTTPProbeOption {
+	return func(p *httpProbe) {
+		p.client = client
+	}
+}
+
+// httpProbe HTTP 探测的配置
+type httpProbe struct {
+	path           string
+	scheme         string
+	expectedStatus []int
+	expectedBody   string
+	client         *http.Client
+}
+
+// HTTPProbe 向服务器的 path 发送 GET 请求，按状态码和响应体判断是否健康
+func HTTPProbe(path string, opts ...HTTPProbeOption) Probe {
+	p := &httpProbe{
+		path:   path,
+		scheme: "http",
+		client: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p.probe
+}
+
+// probe 执行一次 HTTP 探测
+func (p *httpProbe) probe(ctx context.Context, server *Server) error {
+	url := p.scheme + "://" + server.Address + p.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if !p.statusOK(resp.StatusCode) {
+		return fmt.Errorf("loadbalancer: health check %s returned status %d", url, resp.StatusCode)
+	}
+	if p.expectedBody == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httpProbeMaxBody))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(body), p.expectedBody) {
+		return fmt.Errorf("loadbalancer: health check %s response does not contain %q", url, p.expectedBody)
+	}
+	return nil
+}
+
+// statusOK 判断状态码是否符合预期
+func (p *httpProbe) statusOK(code int) bool {
+	if len(p.expectedStatus) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, expected := range p.expectedStatus {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerLister 提供待探测服务器列表，所有负载均衡器都实现了该接口
+type ServerLister interface {
+	GetServers() []*Server
+}
+
+// HealthCheckOption 健康检查的配置项
+type HealthCheckOption func(*HealthChecker)
+
+// WithHealthCheckInterval 设置探测间隔，小于等于0时使用默认值
+func WithHealthCheckInterval(interval time.Duration) HealthCheckOption {
+	return func(h *HealthChecker) {
+		h.interval = interval
+	}
+}
+
+// WithHealthCheckTimeout 设置单次探测的超时时间，小于等于0时使用默认值
+func WithHealthCheckTimeout(timeout time.Duration) HealthCheckOption {
+	return func(h *HealthChecker) {
+		h.timeout = timeout
+	}
+}
+
+// WithHealthCheckJitter 在每次探测间隔上增加 [0, jitter) 的随机抖动，避免多个实例同时探测
+func WithHealthCheckJitter(jitter time.Duration) HealthCheckOption {
+	return func(h *HealthChecker) {
+		h.jitter = jitter
+	}
+}
+
+// WithHealthyThreshold 设置不健康的服务器连续探测成功多少次后恢复为健康
+func WithHealthyThreshold(n int) HealthCheckOption {
+	return func(h *HealthChecker) {
+		h.healthyThreshold = n
+	}
+}
+
+// WithUnhealthyThreshold 设置健康的服务器连续探测失败多少次后标记为不健康
+func WithUnhealthyThreshold(n int) HealthCheckOption {
+	return func(h *HealthChecker) {
+		h.unhealthyThreshold = n
+	}
+}
+
+// HealthChecker 主动健康检查器
+// 按固定间隔并发探测负载均衡器中的所有服务器，连续成功或失败达到阈值后
+// 通过 Server.SetHealthy 更新服务器的健康状态，各负载均衡器在选择时会跳过不健康的服务器。
+// 处于 StatusDraining 或 StatusDisabled 的服务器仍会被探测，但状态不会被健康检查修改
+type HealthChecker struct {
+	lister             ServerLister
+	probe              Probe
+	interval           time.Duration
+	timeout            time.Duration
+	jitter             time.Duration
+	healthyThreshold   int
+	unhealthyThreshold int
+
+	// mu 保护 counters，以及 Start 和 Stop 的状态
+	mu       sync.Mutex
+	counters map[*Server]*healthCounter
+	cancel   context.CancelFunc
+	stopped  chan struct{}
+}
+
+// healthCounter 单台服务器连续探测成功和失败的次数
+type healthCounter struct {
+	successes int
+	failures  int
+}
+
+// NewHealthChecker 创建健康检查器，lister 通常为负载均衡器本身
+func NewHealthChecker(lister ServerLister, probe Probe, opts ...HealthCheckOption) *HealthChecker {
+	h := &HealthChecker{
+		lister:             lister,
+		probe:              probe,
+		interval:           defaultHealthCheckInterval,
+		timeout:            defaultHealthCheckTimeout,
+		healthyThreshold:   defaultHealthyThreshold,
+		unhealthyThreshold: defaultUnhealthyThreshold,
+		counters:           make(map[*Server]*healthCounter),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	// 间隔为0会变成不停探测的死循环，超时为0会使所有探测立即失败
+	if h.interval <= 0 {
+		h.interval = defaultHealthCheckInterval
+	}
+	if h.timeout <= 0 {
+		h.timeout = defaultHealthCheckTimeout
+	}
+	if h.healthyThreshold <= 0 {
+		h.healthyThreshold = 1
+	}
+	if h.unhealthyThreshold <= 0 {
+		h.unhealthyThreshold = 1
+	}
+	return h
+}
+
+// Start 在后台开始周期性探测，立即进行第一轮探测。重复调用不会启动多个探测循环
+func (h *HealthChecker) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.stopped = make(chan struct{})
+	go h.run(ctx, h.stopped)
+}
+
+// Stop 停止探测并等待正在进行的探测结束，服务器保持最后一次的健康状态
+func (h *HealthChecker) Stop() {
+	h.mu.Lock()
+	cancel, stopped := h.cancel, h.stopped
+	h.cancel, h.stopped = nil, nil
+	h.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-stopped
+}
+
+// run 探测循环
+func (h *HealthChecker) run(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	for {
+		h.checkAll(ctx)
+
+		wait := h.interval
+		if h.jitter > 0 {
+			wait += rand.N(h.jitter)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// checkAll 并发探测所有服务器一轮并更新健康状态
+func (h *HealthChecker) checkAll(ctx context.Context) {
+	servers := h.lister.GetServers()
+	errs := make([]error, len(servers))
+
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+			errs[i] = h.probe(probeCtx, server)
+		}()
+	}
+	wg.Wait()
+
+	// 停止时被取消的探测不计入结果
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counters := make(map[*Server]*healthCounter, len(servers))
+	for i, server := range servers {
+		counter := h.counters[server]
+		if counter == nil {
+			counter = &healthCounter{}
+		}
+		counters[server] = counter
+		h.record(server, counter, errs[i])
+	}
+	// 丢弃已移除服务器的计数
+	h.counters = counters
+}
+
+// record 记录一次探测结果，连续次数达到阈值时切换健康状态，调用方需持有锁
+func (h *HealthChecker) record(server *Server, counter *healthCounter, err error) {
+	if err != nil {
+		counter.successes = 0
+		counter.failures++
+		if counter.failures >= h.unhealthyThreshold {
+			server.SetHealthy(false)
+		}
+		return
+	}
+
+	counter.failures = 0
+	counter.successes++
+	if counter.successes >= h.healthyThreshold {
+		server.SetHealthy(true)
+	}
+}
@@ -0,0 +1,263 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestHTTPProbe 验证 HTTP 探测按状态码和响应体判断健康状态
+func TestHTTPProbe(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			fmt.Fprint(w, "status: ok")
+		case "/degraded":
+			fmt.Fprint(w, "status: degraded")
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	server := &Server{Address: strings.TrimPrefix(ts.URL, "http://"), Weight: 1}
+	cases := []struct {
+		name    string
+		probe   Probe
+		healthy bool
+	}{
+		{"2xx", HTTPProbe("/healthz"), true},
+		{"body match", HTTPProbe("/healthz", WithExpectedBody("ok")), true},
+		{"body mismatch", HTTPProbe("/degraded", WithExpectedBody("ok")), false},
+		{"5xx", HTTPProbe("/down"), false},
+		{"expected 503", HTTPProbe("/down", WithExpectedStatus(http.StatusServiceUnavailable)), true},
+	}
+	for _, c := range cases {
+		if err := c.probe(context.Background(), server); (err == nil) != c.healthy {
+			t.Errorf("%s: 探测结果应为健康=%v，实际错误: %v", c.name, c.healthy, err)
+		}
+	}
+}
+
+// TestTCPProbe 验证 TCP 探测在端口可连接时成功，关闭后失败
+func TestTCPProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &Server{Address: listener.Addr().String(), Weight: 1}
+	probe := TCPProbe()
+
+	if err := probe(context.Background(), server); err != nil {
+		t.Errorf("探测可连接的端口失败: %v", err)
+	}
+	listener.Close()
+	if err := probe(context.Background(), server); err == nil {
+		t.Error("探测已关闭的端口不应成功")
+	}
+}
+
+// TestHealthCheckerThresholds 验证连续失败和成功次数达到阈值后才切换健康状态
+func TestHealthCheckerThresholds(t *testing.T) {
+	server := &Server{Address: "server1:8080", Weight: 1}
+	lb := NewRandomLoadBalancer()
+	lb.AddServer(server)
+
+	var probeErr error
+	checker := NewHealthChecker(lb, func(context.Context, *Server) error {
+		return probeErr
+	}, WithUnhealthyThreshold(2), WithHealthyThreshold(3))
+
+	ctx := context.Background()
+	probeErr = errors.New("connection refused")
+	checker.checkAll(ctx)
+	if !server.Healthy() {
+		t.Fatal("失败一次后不应标记为不健康")
+	}
+	checker.checkAll(ctx)
+	if server.Healthy() {
+		t.Fatal("失败两次后应标记为不健康")
+	}
+	if got := lb.GetServer(""); got != nil {
+		t.Fatalf("不应选中不健康的服务器，实际: %s", got.Address)
+	}
+
+	probeErr = nil
+	checker.checkAll(ctx)
+	checker.checkAll(ctx)
+	if server.Healthy() {
+		t.Fatal("成功两次后不应恢复健康")
+	}
+	checker.checkAll(ctx)
+	if !server.Healthy() {
+		t.Fatal("成功三次后应恢复健康")
+	}
+	if got := lb.GetServer(""); got != server {
+		t.Fatalf("应选中恢复的服务器，实际: %v", got)
+	}
+}
+
+// TestHealthCheckerStartStop 验证后台探测会标记故障服务器，Stop 后不再探测
+func TestHealthCheckerStartStop(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	up := &Server{Address: listener.Addr().String(), Weight: 1}
+	defer listener.Close()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := &Server{Address: closed.Addr().String(), Weight: 1}
+	closed.Close()
+
+	lb := NewRoundRobinLoadBalancer(false)
+	lb.UpdateServers([]*Server{up, down})
+
+	var mu sync.Mutex
+	probes := 0
+	tcp := TCPProbe()
+	checker := NewHealthChecker(lb, func(ctx context.Context, server *Server) error {
+		mu.Lock()
+		probes++
+		mu.Unlock()
+		return tcp(ctx, server)
+	}, WithHealthCheckInterval(5*time.Millisecond), WithHealthCheckJitter(time.Millisecond), WithUnhealthyThreshold(2))
+	checker.Start()
+	checker.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for down.Healthy() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	checker.Stop()
+	checker.Stop()
+
+	if down.Healthy() {
+		t.Fatal("已关闭的端口应标记为不健康")
+	}
+	if !up.Healthy() {
+		t.Fatal("可连接的端口不应标记为不健康")
+	}
+	for i := 0; i < 10; i++ {
+		if got := lb.GetServer(""); got != up {
+			t.Fatalf("应选中 %s，实际: %s", up.Address, got.Address)
+		}
+	}
+
+	mu.Lock()
+	stoppedAt := probes
+	mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if probes != stoppedAt {
+		t.Errorf("Stop 后不应继续探测: %d -> %d", stoppedAt, probes)
+	}
+}
+
+// selectorCase 覆盖所有算法的测试用例
+type selectorCase struct {
+	name     string
+	selector Selector
+	// update 设置服务器列表
+	update func(servers []*Server)
+	// release 选择后需要执行的释放操作，可以为nil
+	release func(server *Server)
+}
+
+// newSelectorCases 创建所有算法的负载均衡器，服务器列表由调用方通过 update 设置
+func newSelectorCases() []selectorCase {
+	leastConn := NewLeastConnectionsLoadBalancer(true)
+	p2c := NewPowerOfTwoChoicesLoadBalancer()
+	peakEWMA := NewPeakEWMALoadBalancer(0)
+	jump := NewJumpHashLoadBalancer()
+
+	cases := []selectorCase{
+		{name: "Random", selector: NewRandomLoadBalancer()},
+		{name: "RoundRobin", selector: NewRoundRobinLoadBalancer(false)},
+		{name: "WeightedRoundRobin", selector: NewRoundRobinLoadBalancer(true)},
+		{name: "LeastConnections", selector: leastConn, release: leastConn.ReleaseConnection},
+		{name: "PowerOfTwoChoices", selector: p2c, release: p2c.ReleaseConnection},
+		{name: "PeakEWMA", selector: peakEWMA, release: func(server *Server) {
+			peakEWMA.ReleaseConnection(server, 0)
+		}},
+		{name: "Maglev", selector: NewMaglevHashLoadBalancer()},
+		{name: "RingHash", selector: NewRingHashLoadBalancer()},
+		{name: "Rendezvous", selector: NewRendezvousHashLoadBalancer()},
+		{name: "JumpHash", selector: jump, update: func(servers []*Server) {
+			if err := jump.UpdateServers(servers); err != nil {
+				panic(err)
+			}
+		}},
+	}
+	for i := range cases {
+		if cases[i].update == nil {
+			cases[i].update = cases[i].selector.(LoadBalancer).UpdateServers
+		}
+	}
+	return cases
+}
+
+// newTestKeys 创建 n 个按序编号的key
+func newTestKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	return keys
+}
+
+// TestBalancersSkipUnhealthyServers 验证所有算法都会跳过不健康的服务器，恢复后重新参与选择
+func TestBalancersSkipUnhealthyServers(t *testing.T) {
+	servers := newTestServers(5)
+	keys := newTestKeys(1000)
+	for _, c := range newSelectorCases() {
+		c.update(servers)
+		servers[1].SetHealthy(false)
+		servers[3].SetHealthy(false)
+
+		for _, key := range keys {
+			server := c.selector.GetServer(key)
+			if server == nil || !server.Healthy() {
+				t.Fatalf("%s: key %q 应选中健康的服务器，实际: %v", c.name, key, server)
+			}
+			if c.release != nil {
+				c.release(server)
+			}
+		}
+
+		servers[1].SetHealthy(true)
+		servers[3].SetHealthy(true)
+		// 不释放连接，使基于连接数的算法也会把请求分散到恢复的服务器上
+		seen := make(map[*Server]bool)
+		for _, key := range keys {
+			seen[c.selector.GetServer(key)] = true
+		}
+		if !seen[servers[1]] || !seen[servers[3]] {
+			t.Errorf("%s: 恢复的服务器应重新参与选择", c.name)
+		}
+	}
+}
+
+// TestHealthCheckerInvalidOptions 验证无效的间隔、超时和阈值使用默认值
+func TestHealthCheckerInvalidOptions(t *testing.T) {
+	h := NewHealthChecker(NewRandomLoadBalancer(), TCPProbe(),
+		WithHealthCheckInterval(0), WithHealthCheckTimeout(-time.Second),
+		WithHealthyThreshold(0), WithUnhealthyThreshold(-1))
+	if h.interval != defaultHealthCheckInterval || h.timeout != defaultHealthCheckTimeout {
+		t.Errorf("无效的间隔和超时应使用默认值，实际: %v, %v", h.interval, h.timeout)
+	}
+	if h.healthyThreshold != 1 || h.unhealthyThreshold != 1 {
+		t.Errorf("无效的阈值应为1，实际: %d, %d", h.healthyThreshold, h.unhealthyThreshold)
+	}
+}
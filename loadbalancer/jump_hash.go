@@ -0,0 +1,118 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zeebo/xxh3"
+)
+
+// ErrJumpHashRemoval 跳跃一致性哈希只允许移除末尾的服务器
+var ErrJumpHashRemoval = errors.New("loadbalancer: jump hash only supports removing the last server")
+
+// JumpHashLoadBalancer Jump 一致性哈希负载均衡器
+// 实现 Google 的 jump consistent hash，把 key 映射到服务器列表中的序号。
+// 服务器列表视为有序的分片编号，只支持在末尾追加或移除，不支持权重；
+// 中间的分片不可用时 key 会被重新哈希到其他分片
+type JumpHashLoadBalancer struct {
+	*BaseLoadBalancer
+}
+
+// NewJumpHashLoadBalancer 创建 Jump 一致性哈希负载均衡器
+func NewJumpHashLoadBalancer() *JumpHashLoadBalancer {
+	return &JumpHashLoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+	}
+}
+
+// RemoveServer 按地址移除服务器，只允许移除末尾的服务器
+func (lb *JumpHashLoadBalancer) RemoveServer(address string) error {
+	return lb.removeIf(func(server *Server) bool {
+		return server.Address == address
+	})
+}
+
+// RemoveServerByPointer 按指针移除服务器，只允许移除末尾的服务器
+func (lb *JumpHashLoadBalancer) RemoveServerByPointer(server *Server) error {
+	return lb.removeIf(func(s *Server) bool {
+		return s == server
+	})
+}
+
+// UpdateServers 用给定的服务器列表整体替换当前的服务器，
+// 新旧列表中较短的一个必须是另一个的前缀（按标识比较），即只允许在末尾追加或移除
+func (lb *JumpHashLoadBalancer) UpdateServers(servers []*Server) error {
+	var err error
+	lb.update(func(current []*Server) ([]*Server, bool) {
+		for i := 0; i < len(servers) && i < len(current); i++ {
+			if servers[i].Identity() != current[i].Identity() {
+				err = ErrJumpHashRemoval
+				return current, false
+			}
+		}
+		return append(make([]*Server, 0, len(servers)), servers...), true
+	})
+	return err
+}
+
+// removeIf 移除第一个满足条件的服务器，该服务器不在末尾时返回 ErrJumpHashRemoval
+func (lb *JumpHashLoadBalancer) removeIf(match func(server *Server) bool) error {
+	var err error
+	lb.update(func(servers []*Server) ([]*Server, bool) {
+		for i, server := range servers {
+			if !match(server) {
+				continue
+			}
+			if i != len(servers)-1 {
+				err = ErrJumpHashRemoval
+				return servers, false
+			}
+			return servers[:i], true
+		}
+		return servers, false
+	})
+	return err
+}
+
+// GetServer 根据key获取服务器
+func (lb *JumpHashLoadBalancer) GetServer(key string) *Server {
+	servers := lb.GetServers()
+	if len(servers) == 0 {
+		return nil
+	}
+
+	hash := xxh3.HashString(key)
+	index := 0
+	for attempt := 0; attempt < len(servers); attempt++ {
+		index = jumpHash(hash, len(servers))
+		if servers[index].Available() {
+			return servers[index]
+		}
+		// 分片不可用时使用新的种子重新哈希
+		hash = xxh3.HashStringSeed(key, uint64(attempt+1))
+	}
+
+	// 重新哈希仍未找到时从最后一次的位置顺序查找
+	for i := 1; i < len(servers); i++ {
+		if server := servers[(index+i)%len(servers)]; server.Available() {
+			return server
+		}
+	}
+	return nil
+}
+
+// Pick 根据key选择服务器并返回选择句柄
+func (lb *JumpHashLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, nil)
+}
+
+// jumpHash Google 的 jump consistent hash，返回 [0, buckets) 内的分片序号
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
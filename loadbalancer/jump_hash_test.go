@@ -0,0 +1,115 @@
+package loadbalancer
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// newJumpHashServers 创建 n 台按序编号的服务器
+func newJumpHashServers(n int) []*Server {
+	servers := make([]*Server, n)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("shard-%d", i), Weight: 1}
+	}
+	return servers
+}
+
+// TestJumpHashReferenceValues 与论文算法的参考实现（Guava consistentHash、go-jump）的结果对比
+func TestJumpHashReferenceValues(t *testing.T) {
+	cases := []struct {
+		key     uint64
+		buckets int
+		want    int
+	}{
+		{1, 1, 0},
+		{42, 57, 43},
+		{0xDEAD10CC, 1, 0},
+		{0xDEAD10CC, 666, 361},
+		{256, 1024, 520},
+	}
+	for _, c := range cases {
+		if got := jumpHash(c.key, c.buckets); got != c.want {
+			t.Errorf("jumpHash(%d, %d) 应为%d，实际: %d", c.key, c.buckets, c.want, got)
+		}
+	}
+
+	golden100 := []int{0, 55, 62, 8, 45, 59, 86, 97, 82, 59, 73, 37, 17, 56, 86, 21, 90, 37, 38, 83}
+	for key, want := range golden100 {
+		if got := jumpHash(uint64(key), 100); got != want {
+			t.Errorf("jumpHash(%d, 100) 应为%d，实际: %d", key, want, got)
+		}
+	}
+}
+
+// TestJumpHashAppendMovesFewKeys 验证在末尾追加分片时只有约 1/(n+1) 的key移动，且都移动到新分片
+func TestJumpHashAppendMovesFewKeys(t *testing.T) {
+	lb := NewJumpHashLoadBalancer()
+	servers := newJumpHashServers(11)
+	if err := lb.UpdateServers(servers[:10]); err != nil {
+		t.Fatal(err)
+	}
+
+	const keyCount = 100000
+	assignments := make([]*Server, keyCount)
+	for i := range assignments {
+		assignments[i] = lb.GetServer(fmt.Sprintf("key%d", i))
+	}
+
+	lb.AddServer(servers[10])
+	moved := 0
+	for i, before := range assignments {
+		after := lb.GetServer(fmt.Sprintf("key%d", i))
+		if after == before {
+			continue
+		}
+		if after != servers[10] {
+			t.Fatalf("key%d 应移动到新分片，实际: %s -> %s", i, before.Address, after.Address)
+		}
+		moved++
+	}
+	if share := float64(moved) / keyCount; share < 0.08 || share > 0.10 {
+		t.Errorf("移动的key比例应约为1/11，实际: %.4f", share)
+	}
+}
+
+// TestJumpHashRemoval 验证只允许移除末尾的服务器，UpdateServers 只接受前缀或追加
+func TestJumpHashRemoval(t *testing.T) {
+	lb := NewJumpHashLoadBalancer()
+	servers := newJumpHashServers(4)
+	if err := lb.UpdateServers(servers); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lb.RemoveServerByPointer(servers[1]); !errors.Is(err, ErrJumpHashRemoval) {
+		t.Errorf("移除中间的服务器应返回 ErrJumpHashRemoval，实际: %v", err)
+	}
+	if err := lb.RemoveServer(servers[2].Address); !errors.Is(err, ErrJumpHashRemoval) {
+		t.Errorf("按地址移除中间的服务器应返回 ErrJumpHashRemoval，实际: %v", err)
+	}
+	if got := len(lb.GetServers()); got != 4 {
+		t.Fatalf("移除被拒绝后服务器数量应为4，实际: %d", got)
+	}
+
+	if err := lb.RemoveServerByPointer(servers[3]); err != nil {
+		t.Errorf("移除末尾的服务器失败: %v", err)
+	}
+	if got := len(lb.GetServers()); got != 3 {
+		t.Fatalf("移除末尾的服务器后数量应为3，实际: %d", got)
+	}
+
+	// 替换中间的分片会改变后续分片的序号
+	reordered := []*Server{servers[0], servers[2], servers[1]}
+	if err := lb.UpdateServers(reordered); !errors.Is(err, ErrJumpHashRemoval) {
+		t.Errorf("调整顺序的 UpdateServers 应返回 ErrJumpHashRemoval，实际: %v", err)
+	}
+	if err := lb.UpdateServers(servers[:2]); err != nil {
+		t.Errorf("保留前缀的 UpdateServers 失败: %v", err)
+	}
+	if err := lb.UpdateServers(servers); err != nil {
+		t.Errorf("追加服务器的 UpdateServers 失败: %v", err)
+	}
+	if got := len(lb.GetServers()); got != 4 {
+		t.Errorf("服务器数量应为4，实际: %d", got)
+	}
+}
@@ -1,39 +1,46 @@
 package loadbalancer
 
 import (
-	"sync"
-	"sync/atomic"
+	"context"
 )
 
+// LeastConnectionsOption 最小连接负载均衡器的配置项
+type LeastConnectionsOption func(*LeastConnectionsLoadBalancer)
+
+// WithLeastConnectionsSlowStart 为新加入的服务器启用慢启动，只对加权最小连接生效。
+// 启用后加权值为 (连接数+1)/有效权重，否则连接数为0的新服务器仍会被优先选择
+func WithLeastConnectionsSlowStart(config SlowStartConfig) LeastConnectionsOption {
+	return func(lb *LeastConnectionsLoadBalancer) {
+		lb.slowStart = newSlowStart(config)
+	}
+}
+
 // LeastConnectionsLoadBalancer 最小连接负载均衡器
 type LeastConnectionsLoadBalancer struct {
 	*BaseLoadBalancer
-	connections map[*Server]*int64
+	connections *connectionTracker
 	weighted    bool
-	mu          sync.RWMutex
+	slowStart   *slowStart
 }
 
 // NewLeastConnectionsLoadBalancer 创建最小连接负载均衡器
-func NewLeastConnectionsLoadBalancer(weighted bool) *LeastConnectionsLoadBalancer {
-	return &LeastConnectionsLoadBalancer{
+func NewLeastConnectionsLoadBalancer(weighted bool, opts ...LeastConnectionsOption) *LeastConnectionsLoadBalancer {
+	lb := &LeastConnectionsLoadBalancer{
 		BaseLoadBalancer: NewBaseLoadBalancer(),
-		connections:      make(map[*Server]*int64),
+		connections:      newConnectionTracker(),
 		weighted:         weighted,
 	}
+	for _, opt := range opts {
+		opt(lb)
+	}
+	lb.onChange = lb.syncConnections
+	return lb
 }
 
 // GetServer 获取连接数最少的服务器
 func (lb *LeastConnectionsLoadBalancer) GetServer(key string) *Server {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
-	// 过滤出可用的服务器 - 检查Weight大于0的服务器（表示可用）
-	availableServers := make([]*Server, 0)
-	for _, server := range lb.Servers {
-		if server.Weight > 0 { // 使用Weight > 0作为可用性判断
-			availableServers = append(availableServers, server)
-		}
-	}
+	// 可用服务器已在快照中预先筛选好
+	availableServers := lb.loadSnapshot().availableServers
 
 	if len(availableServers) == 0 {
 		return nil
@@ -42,20 +49,26 @@ func (lb *LeastConnectionsLoadBalancer) GetServer(key string) *Server {
 	// 找到连接数最少的服务器
 	var selectedServer *Server
 	minValue := float64(1<<63 - 1)
+	now, ramping := lb.slowStart.active()
 
 	for _, server := range availableServers {
-		connPtr := lb.connections[server]
-		if connPtr == nil {
-			var conn int64
-			connPtr = &conn
-			lb.connections[server] = connPtr
+		// 跳过探测名额已满的半开服务器，否则重试时会再次选中它
+		if server.circuitBlocked() {
+			continue
 		}
-		connections := atomic.LoadInt64(connPtr)
+		connections := lb.connections.load(server)
 
 		var currentValue float64
 		if lb.weighted {
 			// 加权最小连接：考虑权重因素
-			if server.Weight > 0 {
+			if lb.slowStart != nil {
+				// 慢启动：有效权重随加入时间增长，连接数加1使空闲的新服务器不会总是胜出
+				weight := float64(server.Weight)
+				if ramping {
+					weight = lb.slowStart.weight(server, now)
+				}
+				currentValue = float64(connections+1) / weight
+			} else if server.Weight > 0 {
 				// 权重越大，加权值越小，越容易被选中
 				currentValue = float64(connections) / float64(server.Weight)
 			} else {
@@ -81,50 +94,26 @@ func (lb *LeastConnectionsLoadBalancer) GetServer(key string) *Server {
 
 	if selectedServer != nil {
 		// 增加选中服务器的连接数
-		atomic.AddInt64(lb.connections[selectedServer], 1)
-		// 同时更新Server结构体中的CurrentConnections字段，方便外部查看
-		atomic.AddInt32(&selectedServer.CurrentConnections, 1)
+		lb.connections.acquire(selectedServer)
 	}
 
 	return selectedServer
 }
 
-// ReleaseConnection 释放连接
-func (lb *LeastConnectionsLoadBalancer) ReleaseConnection(server *Server) {
-	if server == nil {
-		return
-	}
-
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
-	if connPtr, exists := lb.connections[server]; exists && atomic.LoadInt64(connPtr) > 0 {
-		atomic.AddInt64(connPtr, -1)
-		// 同时更新Server结构体中的CurrentConnections字段
-		if server.CurrentConnections > 0 {
-			atomic.AddInt32(&server.CurrentConnections, -1)
-		}
-	}
+// Pick 选择连接数最少的服务器并返回选择句柄，句柄的 Done 会释放连接
+func (lb *LeastConnectionsLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, func(server *Server, _ Result) {
+		lb.ReleaseConnection(server)
+	})
 }
 
-// AddServer 添加服务器
-func (lb *LeastConnectionsLoadBalancer) AddServer(server *Server) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-	lb.Servers = append(lb.Servers, server)
-	var conn int64
-	lb.connections[server] = &conn
+// ReleaseConnection 释放连接
+func (lb *LeastConnectionsLoadBalancer) ReleaseConnection(server *Server) {
+	lb.connections.release(server)
 }
 
-// RemoveServer 移除服务器
-func (lb *LeastConnectionsLoadBalancer) RemoveServer(server *Server) {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-	for i, s := range lb.Servers {
-		if s == server {
-			lb.Servers = append(lb.Servers[:i], lb.Servers[i+1:]...)
-			delete(lb.connections, server)
-			break
-		}
-	}
+// syncConnections 根据新的服务器列表同步连接计数和慢启动状态
+func (lb *LeastConnectionsLoadBalancer) syncConnections(servers []*Server) {
+	lb.slowStart.sync(lb.GetServers(), servers)
+	lb.connections.sync(servers)
 }
@@ -1,66 +1,258 @@
 package loadbalancer
 
 import (
+	"context"
+	"slices"
 	"sync"
+	"sync/atomic"
 )
 
 // Server 表示一个后端服务器
 type Server struct {
+	// ID 服务器的稳定标识，为空时使用Address，一致性哈希类算法据此放置服务器
+	ID      string
 	Address string
 	Weight  int
 	// 用于最小连接算法的当前连接数
 	CurrentConnections int32
-	// 用于轮询算法的当前权重
-	CurrentWeight int
-	// 用于轮询算法的有效权重
-	EffectiveWeight int
+	// status 服务器状态（ServerStatus），零值为 StatusHealthy
+	status atomic.Int32
+	// statusHooks 状态变化回调，以 copy-on-write 方式更新
+	statusHooks atomic.Pointer[[]StatusChangeFunc]
+	// ejections 当前驱逐该服务器的异常检测器个数
+	ejections atomic.Int32
+	// breaker 服务器的熔断器，可以为nil
+	breaker atomic.Pointer[CircuitBreaker]
+}
+
+// Identity 获取服务器的稳定标识，优先使用ID，否则使用Address
+func (s *Server) Identity() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return s.Address
+}
+
+// Available 服务器是否可以接收新请求：权重大于0、状态为 StatusHealthy、没有被异常检测驱逐，
+// 并且熔断器允许新请求。权重只表示容量，摘除、下线等状态通过 SetStatus 表达，不需要修改权重
+func (s *Server) Available() bool {
+	return s.snapshotAvailable() && !s.circuitBlocked()
+}
+
+// snapshotAvailable 快照使用的可用性判断，不考虑半开状态的探测名额。
+// 探测名额随每个请求变化，计入快照会导致频繁重建，选择时由 Pick 中的 allow 检查
+func (s *Server) snapshotAvailable() bool {
+	return s.Weight > 0 && s.Healthy() && !s.Ejected() && !s.circuitOpen()
 }
 
 // LoadBalancer 定义负载均衡器接口
 type LoadBalancer interface {
 	// AddServer 添加服务器
 	AddServer(server *Server)
-	// RemoveServer 移除服务器
+	// RemoveServer 按地址移除服务器
 	RemoveServer(address string)
+	// RemoveServerByPointer 按指针移除服务器
+	RemoveServerByPointer(server *Server)
+	// UpdateServer 用新的配置替换地址相同的服务器
+	UpdateServer(server *Server)
+	// UpdateServers 用给定的服务器列表整体替换当前的服务器
+	UpdateServers(servers []*Server)
 	// GetServer 获取下一个服务器
 	GetServer(key string) *Server
+	// Pick 选择一个服务器并返回选择句柄，请求结束后需调用句柄的 Done 回报结果
+	Pick(ctx context.Context, key string) (*Selection, error)
+	// OnDone 注册请求完成回调
+	OnDone(fn DoneFunc)
+	// GetServerCount 获取服务器数量
+	GetServerCount() int
 }
 
+// Selector 按key选择服务器的约定，不支持任意移除服务器的 JumpHashLoadBalancer 也实现了该接口
+type Selector interface {
+	// GetServer 获取下一个服务器
+	GetServer(key string) *Server
+	// Pick 选择一个服务器并返回选择句柄
+	Pick(ctx context.Context, key string) (*Selection, error)
+}
+
+// 编译期检查各算法是否实现了相应接口
+var (
+	_ LoadBalancer = (*RandomLoadBalancer)(nil)
+	_ LoadBalancer = (*RoundRobinLoadBalancer)(nil)
+	_ LoadBalancer = (*LeastConnectionsLoadBalancer)(nil)
+	_ LoadBalancer = (*MaglevHashLoadBalancer)(nil)
+	_ LoadBalancer = (*PowerOfTwoChoicesLoadBalancer)(nil)
+	_ LoadBalancer = (*PeakEWMALoadBalancer)(nil)
+	_ LoadBalancer = (*RingHashLoadBalancer)(nil)
+	_ LoadBalancer = (*RendezvousHashLoadBalancer)(nil)
+	_ Selector     = (*JumpHashLoadBalancer)(nil)
+)
+
 // BaseLoadBalancer 基础负载均衡器结构
+// 服务器列表以不可变快照的形式保存在原子指针中，读路径无需加锁；
+// 增删改时复制一份新的列表并整体替换（copy-on-write），写操作之间由 mu 串行化
 type BaseLoadBalancer struct {
-	Servers []*Server
-	mu      sync.RWMutex
+	snapshot atomic.Pointer[serverSnapshot]
+	mu       sync.Mutex
+	// onChange 在新快照发布前调用（调用时持有写锁），供具体算法基于新的服务器列表重建内部状态
+	onChange func(servers []*Server)
+	// doneHooks 请求完成时调用的回调，同样以 copy-on-write 方式更新
+	doneHooks atomic.Pointer[[]DoneFunc]
+}
+
+// serverSnapshot 服务器列表的不可变快照，发布后不再修改
+// 可用服务器及其加权随机用的别名表在发布前预先计算好，选择路径上不需要再分配内存
+type serverSnapshot struct {
+	servers []*Server
+	// availableServers 构建时可用的服务器，可能包含探测名额已满的半开服务器
+	availableServers []*Server
+	// weights 可用服务器的别名表，没有可用服务器时为nil
+	weights *aliasTable
+	// totalWeight 可用服务器的权重之和
+	totalWeight int64
+	// epoch 计算可用服务器时的服务器状态版本号
+	epoch uint64
+}
+
+// newServerSnapshot 根据服务器列表创建快照
+func newServerSnapshot(servers []*Server) *serverSnapshot {
+	snapshot := &serverSnapshot{
+		servers:          servers,
+		availableServers: make([]*Server, 0, len(servers)),
+		// 先读取版本号再判断可用性，期间发生的状态变化会使快照在下次读取时重建
+		epoch: statusEpoch.Load(),
+	}
+	weights := make([]int, 0, len(servers))
+	for _, server := range servers {
+		if server.snapshotAvailable() {
+			snapshot.availableServers = append(snapshot.availableServers, server)
+			weights = append(weights, server.Weight)
+			snapshot.totalWeight += int64(server.Weight)
+		}
+	}
+	if len(weights) > 0 {
+		snapshot.weights = newAliasTable(weights)
+	}
+	return snapshot
+}
+
+// weightedResamples 按权重选中的服务器探测名额已满时的最大重新采样次数
+const weightedResamples = 3
+
+// weightedServer 按权重随机选择一台熔断器允许新请求的可用服务器，时间复杂度 O(1)，
+// uint64N 返回 [0, n) 内的随机数，必须是并发安全的。快照不反映半开状态的探测名额，
+// 选中探测名额已满的服务器时重新采样，仍未找到时顺序查找，都不允许时返回nil
+func (s *serverSnapshot) weightedServer(uint64N func(n uint64) uint64) *Server {
+	if s.weights == nil {
+		return nil
+	}
+	index := s.weights.index(uint64N(s.weights.span()))
+	for i := 0; i < weightedResamples && s.availableServers[index].circuitBlocked(); i++ {
+		index = s.weights.index(uint64N(s.weights.span()))
+	}
+	for i := range s.availableServers {
+		if server := s.availableServers[(index+i)%len(s.availableServers)]; !server.circuitBlocked() {
+			return server
+		}
+	}
+	return nil
 }
 
 // NewBaseLoadBalancer 创建基础负载均衡器
 func NewBaseLoadBalancer() *BaseLoadBalancer {
-	return &BaseLoadBalancer{
-		Servers: make([]*Server, 0),
-	}
+	b := &BaseLoadBalancer{}
+	b.snapshot.Store(newServerSnapshot(make([]*Server, 0)))
+	return b
+}
+
+// GetServers 获取当前服务器列表的快照，返回的切片不可修改
+func (b *BaseLoadBalancer) GetServers() []*Server {
+	return b.snapshot.Load().servers
 }
 
 // AddServer 添加服务器
 func (b *BaseLoadBalancer) AddServer(server *Server) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	b.Servers = append(b.Servers, server)
+	b.update(func(servers []*Server) ([]*Server, bool) {
+		return append(servers, server), true
+	})
 }
 
 // RemoveServer 移除服务器
 func (b *BaseLoadBalancer) RemoveServer(address string) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-	for i, server := range b.Servers {
-		if server.Address == address {
-			b.Servers = append(b.Servers[:i], b.Servers[i+1:]...)
-			break
+	b.update(func(servers []*Server) ([]*Server, bool) {
+		for i, server := range servers {
+			if server.Address == address {
+				return append(servers[:i], servers[i+1:]...), true
+			}
+		}
+		return servers, false
+	})
+}
+
+// RemoveServerByPointer 按指针移除服务器
+func (b *BaseLoadBalancer) RemoveServerByPointer(server *Server) {
+	b.update(func(servers []*Server) ([]*Server, bool) {
+		for i, s := range servers {
+			if s == server {
+				return append(servers[:i], servers[i+1:]...), true
+			}
+		}
+		return servers, false
+	})
+}
+
+// UpdateServer 用新的配置替换地址相同的服务器，不存在时不做任何操作
+func (b *BaseLoadBalancer) UpdateServer(server *Server) {
+	b.update(func(servers []*Server) ([]*Server, bool) {
+		for i, s := range servers {
+			if s.Address == server.Address {
+				servers[i] = server
+				return servers, true
+			}
 		}
+		return servers, false
+	})
+}
+
+// UpdateServers 用给定的服务器列表整体替换当前的服务器，只触发一次内部状态重建，
+// 适合滚动发布等批量变更成员的场景
+func (b *BaseLoadBalancer) UpdateServers(servers []*Server) {
+	b.update(func([]*Server) ([]*Server, bool) {
+		return append(make([]*Server, 0, len(servers)), servers...), true
+	})
+}
+
+// loadSnapshot 获取当前快照，服务器状态变化后第一次读取时重建可用服务器列表。
+// 重建的快照通过 CompareAndSwap 发布，与并发的成员变更冲突时以成员变更为准
+func (b *BaseLoadBalancer) loadSnapshot() *serverSnapshot {
+	snapshot := b.snapshot.Load()
+	if snapshot.epoch == statusEpoch.Load() {
+		return snapshot
 	}
+
+	rebuilt := newServerSnapshot(snapshot.servers)
+	if b.snapshot.CompareAndSwap(snapshot, rebuilt) {
+		return rebuilt
+	}
+	return b.snapshot.Load()
 }
 
 // GetServerCount 获取服务器数量
 func (b *BaseLoadBalancer) GetServerCount() int {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-	return len(b.Servers)
+	return len(b.snapshot.Load().servers)
+}
+
+// update 在写锁内基于当前服务器列表的副本计算新的列表并发布，fn 返回false表示没有变更
+func (b *BaseLoadBalancer) update(fn func(servers []*Server) ([]*Server, bool)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	servers, changed := fn(slices.Clone(b.snapshot.Load().servers))
+	if !changed {
+		return
+	}
+	if b.onChange != nil {
+		b.onChange(servers)
+	}
+	b.snapshot.Store(newServerSnapshot(servers))
 }
@@ -0,0 +1,403 @@
+package loadbalancer
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultOutlierInterval 默认的统计周期
+	defaultOutlierInterval = 10 * time.Second
+	// defaultConsecutiveFailures 默认连续失败多少次后驱逐
+	defaultConsecutiveFailures = 5
+	// defaultConsecutiveGatewayFailures 默认连续网关错误多少次后驱逐
+	defaultConsecutiveGatewayFailures = 5
+	// defaultBaseEjectionTime 默认的基础驱逐时间
+	defaultBaseEjectionTime = 30 * time.Second
+	// defaultMaxEjectionTime 默认的最长驱逐时间
+	defaultMaxEjectionTime = 300 * time.Second
+	// defaultMaxEjectionPercent 默认最多驱逐的服务器比例
+	defaultMaxEjectionPercent = 10
+	// defaultSuccessRateMinimumHosts 默认参与成功率检测的最少服务器数
+	defaultSuccessRateMinimumHosts = 5
+	// defaultSuccessRateRequestVolume 默认一个统计周期内参与成功率检测的最少请求数
+	defaultSuccessRateRequestVolume = 100
+	// defaultSuccessRateStdevFactor 默认的成功率标准差系数
+	defaultSuccessRateStdevFactor = 1.9
+)
+
+// OutlierDetectorOption 异常检测的配置项
+type OutlierDetectorOption func(*OutlierDetector)
+
+// WithOutlierInterval 设置统计周期，每个周期结束时进行成功率检测并恢复到期的服务器，小于等于0时使用默认值
+func WithOutlierInterval(interval time.Duration) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.interval = interval
+	}
+}
+
+// WithConsecutiveFailures 设置连续失败多少次后驱逐，失败包括请求出错和 5xx，为0表示不检测
+func WithConsecutiveFailures(n int) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.consecutiveFailures = n
+	}
+}
+
+// WithConsecutiveGatewayFailures 设置连续网关错误（502、503、504）多少次后驱逐，为0表示不检测
+func WithConsecutiveGatewayFailures(n int) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.consecutiveGatewayFailures = n
+	}
+}
+
+// WithEjectionTime 设置基础驱逐时间和最长驱逐时间，
+// 实际驱逐时间为 基础驱逐时间 * 2^(驱逐次数-1)，不超过最长驱逐时间
+func WithEjectionTime(base, maxTime time.Duration) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.baseEjectionTime = base
+		d.maxEjectionTime = maxTime
+	}
+}
+
+// WithMaxEjectionPercent 设置最多驱逐的服务器百分比，无论比例多少至少允许驱逐一台
+func WithMaxEjectionPercent(percent int) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.maxEjectionPercent = percent
+	}
+}
+
+// WithSuccessRate 设置成功率检测：一个统计周期内请求数不少于 requestVolume 的服务器
+// 达到 minimumHosts 台时，成功率低于 平均值 - stdevFactor*标准差 的服务器会被驱逐。
+// minimumHosts 为0表示不检测
+func WithSuccessRate(minimumHosts, requestVolume int, stdevFactor float64) OutlierDetectorOption {
+	return func(d *OutlierDetector) {
+		d.successRateMinimumHosts = minimumHosts
+		d.successRateRequestVolume = requestVolume
+		d.successRateStdevFactor = stdevFactor
+	}
+}
+
+// OutlierDetector 被动异常检测（参考 Envoy 的 outlier detection）
+// 根据请求结果统计每台服务器的连续失败、连续网关错误和成功率，暂时驱逐异常的服务器，
+// 驱逐时间随驱逐次数指数增长，到期后在统计周期结束或下一次 Record 时恢复，不需要主动探测。
+// 通过 lb.OnDone(detector.Record) 接入负载均衡器，被驱逐的服务器不会被任何算法选中。
+// 每个检测器只恢复自己驱逐的服务器，同一台服务器加入多个负载均衡器时互不影响
+type OutlierDetector struct {
+	lister                     ServerLister
+	interval                   time.Duration
+	consecutiveFailures        int
+	consecutiveGatewayFailures int
+	baseEjectionTime           time.Duration
+	maxEjectionTime            time.Duration
+	maxEjectionPercent         int
+	successRateMinimumHosts    int
+	successRateRequestVolume   int
+	successRateStdevFactor     float64
+	// now 获取当前时间，测试时可以替换
+	now func() time.Time
+
+	// mu 串行化驱逐决策和周期检测，并保护 stats 的替换、ejected 以及 Start 和 Stop 的状态
+	mu sync.Mutex
+	// stats 服务器到统计数据的映射，以 copy-on-write 方式更新，Record 读取时不需要加锁
+	stats atomic.Pointer[map[*Server]*outlierStat]
+	// ejected 本检测器驱逐的服务器
+	ejected map[*Server]*outlierStat
+	// nextRestore 最早到期的驱逐时间（UnixNano），为0表示没有驱逐，Record 据此无锁判断是否需要恢复
+	nextRestore atomic.Int64
+	cancel      context.CancelFunc
+	stopped     chan struct{}
+}
+
+// outlierStat 单台服务器的异常检测统计
+type outlierStat struct {
+	mu                         sync.Mutex
+	consecutiveFailures        int
+	consecutiveGatewayFailures int
+	// successes 和 requests 为当前统计周期内的成功数和请求数
+	successes int
+	requests  int
+	// ejections 驱逐次数，决定下一次的驱逐时间，服务器持续正常时逐周期递减
+	ejections int
+	// ejectedUntil 驱逐的到期时间，零值表示没有被驱逐
+	ejectedUntil time.Time
+}
+
+// NewOutlierDetector 创建异常检测器，lister 通常为负载均衡器本身
+func NewOutlierDetector(lister ServerLister, opts ...OutlierDetectorOption) *OutlierDetector {
+	d := &OutlierDetector{
+		lister:                     lister,
+		interval:                   defaultOutlierInterval,
+		consecutiveFailures:        defaultConsecutiveFailures,
+		consecutiveGatewayFailures: defaultConsecutiveGatewayFailures,
+		baseEjectionTime:           defaultBaseEjectionTime,
+		maxEjectionTime:            defaultMaxEjectionTime,
+		maxEjectionPercent:         defaultMaxEjectionPercent,
+		successRateMinimumHosts:    defaultSuccessRateMinimumHosts,
+		successRateRequestVolume:   defaultSuccessRateRequestVolume,
+		successRateStdevFactor:     defaultSuccessRateStdevFactor,
+		now:                        time.Now,
+		ejected:                    make(map[*Server]*outlierStat),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.interval <= 0 {
+		d.interval = defaultOutlierInterval
+	}
+	if d.maxEjectionTime < d.baseEjectionTime {
+		d.maxEjectionTime = d.baseEjectionTime
+	}
+	d.stats.Store(&map[*Server]*outlierStat{})
+	return d
+}
+
+// Record 记录一次请求结果，签名与 DoneFunc 一致，可以直接通过 OnDone 注册。
+// 同时恢复已经到期的驱逐，因此没有调用 Start 时驱逐也会到期恢复
+func (d *OutlierDetector) Record(server *Server, result Result) {
+	if server == nil {
+		return
+	}
+	if next := d.nextRestore.Load(); next != 0 {
+		if now := d.now(); now.UnixNano() >= next {
+			d.mu.Lock()
+			d.restoreExpired(now)
+			d.mu.Unlock()
+		}
+	}
+	stat := d.stat(server)
+
+	stat.mu.Lock()
+	stat.requests++
+	if result.Success() {
+		stat.successes++
+		stat.consecutiveFailures = 0
+	} else {
+		stat.consecutiveFailures++
+	}
+	if result.GatewayError() {
+		stat.consecutiveGatewayFailures++
+	} else {
+		stat.consecutiveGatewayFailures = 0
+	}
+	eject := (d.consecutiveFailures > 0 && stat.consecutiveFailures >= d.consecutiveFailures) ||
+		(d.consecutiveGatewayFailures > 0 && stat.consecutiveGatewayFailures >= d.consecutiveGatewayFailures)
+	stat.mu.Unlock()
+
+	if eject {
+		d.mu.Lock()
+		d.eject(server, stat, d.now(), d.lister.GetServers())
+		d.mu.Unlock()
+	}
+}
+
+// Start 在后台按统计周期进行检测。重复调用不会启动多个检测循环
+func (d *OutlierDetector) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.stopped = make(chan struct{})
+	go d.run(ctx, d.stopped)
+}
+
+// Stop 停止后台检测，已被驱逐的服务器在到期后的下一次 Record 时恢复
+func (d *OutlierDetector) Stop() {
+	d.mu.Lock()
+	cancel, stopped := d.cancel, d.stopped
+	d.cancel, d.stopped = nil, nil
+	d.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-stopped
+}
+
+// run 检测循环
+func (d *OutlierDetector) run(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.evaluate(d.now())
+		}
+	}
+}
+
+// stat 获取服务器的统计数据，不存在时创建
+func (d *OutlierDetector) stat(server *Server) *outlierStat {
+	if stat := (*d.stats.Load())[server]; stat != nil {
+		return stat
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := *d.stats.Load()
+	if stat := stats[server]; stat != nil {
+		return stat
+	}
+	updated := make(map[*Server]*outlierStat, len(stats)+1)
+	for s, stat := range stats {
+		updated[s] = stat
+	}
+	stat := &outlierStat{}
+	updated[server] = stat
+	d.stats.Store(&updated)
+	return stat
+}
+
+// evaluate 结束一个统计周期：恢复到期的服务器、递减驱逐次数并进行成功率检测
+func (d *OutlierDetector) evaluate(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	servers := d.lister.GetServers()
+	old := *d.stats.Load()
+	stats := make(map[*Server]*outlierStat, len(servers))
+	for _, server := range servers {
+		if stat := old[server]; stat != nil {
+			stats[server] = stat
+		}
+	}
+	// 已移除的服务器不再参与检测，撤销本检测器对其的驱逐
+	for server, stat := range d.ejected {
+		if stats[server] == nil {
+			stat.mu.Lock()
+			d.restore(server, stat)
+			stat.mu.Unlock()
+		}
+	}
+	d.stats.Store(&stats)
+	defer d.resetNextRestore()
+
+	type sample struct {
+		server *Server
+		stat   *outlierStat
+		rate   float64
+	}
+	samples := make([]sample, 0, len(stats))
+	for _, server := range servers {
+		stat := stats[server]
+		if stat == nil {
+			continue
+		}
+
+		stat.mu.Lock()
+		if !stat.ejectedUntil.IsZero() {
+			if !now.Before(stat.ejectedUntil) {
+				d.restore(server, stat)
+			}
+		} else if stat.ejections > 0 {
+			// 一个周期内保持正常，降低下一次的驱逐时间
+			stat.ejections--
+		}
+		if stat.ejectedUntil.IsZero() && d.successRateMinimumHosts > 0 && stat.requests >= d.successRateRequestVolume && stat.requests > 0 {
+			samples = append(samples, sample{server, stat, float64(stat.successes) / float64(stat.requests)})
+		}
+		stat.successes, stat.requests = 0, 0
+		stat.mu.Unlock()
+	}
+
+	if d.successRateMinimumHosts <= 0 || len(samples) < d.successRateMinimumHosts {
+		return
+	}
+	var mean float64
+	for _, s := range samples {
+		mean += s.rate
+	}
+	mean /= float64(len(samples))
+	var variance float64
+	for _, s := range samples {
+		variance += (s.rate - mean) * (s.rate - mean)
+	}
+	threshold := mean - d.successRateStdevFactor*math.Sqrt(variance/float64(len(samples)))
+	for _, s := range samples {
+		if s.rate < threshold {
+			d.eject(s.server, s.stat, now, servers)
+		}
+	}
+}
+
+// eject 驱逐服务器，超过最大驱逐比例或已被驱逐时不做任何操作，调用方需持有 d.mu。
+// 驱逐比例只统计本检测器驱逐的服务器
+func (d *OutlierDetector) eject(server *Server, stat *outlierStat, now time.Time, servers []*Server) {
+	ejected := 0
+	for _, s := range servers {
+		if d.ejected[s] != nil {
+			ejected++
+		}
+	}
+	if ejected > 0 && float64(ejected+1)*100 > float64(d.maxEjectionPercent*len(servers)) {
+		return
+	}
+
+	stat.mu.Lock()
+	defer stat.mu.Unlock()
+
+	if !stat.ejectedUntil.IsZero() {
+		return
+	}
+	stat.ejections++
+	// 每多驱逐一次翻倍，逐次比较避免移位溢出
+	ejectionTime := d.baseEjectionTime
+	for i := 1; i < stat.ejections && ejectionTime < d.maxEjectionTime; i++ {
+		ejectionTime <<= 1
+	}
+	if ejectionTime > d.maxEjectionTime {
+		ejectionTime = d.maxEjectionTime
+	}
+	stat.ejectedUntil = now.Add(ejectionTime)
+	stat.consecutiveFailures = 0
+	stat.consecutiveGatewayFailures = 0
+	d.ejected[server] = stat
+	server.eject()
+	if next := d.nextRestore.Load(); next == 0 || stat.ejectedUntil.UnixNano() < next {
+		d.nextRestore.Store(stat.ejectedUntil.UnixNano())
+	}
+}
+
+// restore 撤销本检测器对服务器的驱逐，调用方需持有 d.mu 和 stat.mu
+func (d *OutlierDetector) restore(server *Server, stat *outlierStat) {
+	stat.ejectedUntil = time.Time{}
+	delete(d.ejected, server)
+	server.restore()
+}
+
+// restoreExpired 恢复所有到期的驱逐，调用方需持有 d.mu
+func (d *OutlierDetector) restoreExpired(now time.Time) {
+	for server, stat := range d.ejected {
+		stat.mu.Lock()
+		if !now.Before(stat.ejectedUntil) {
+			d.restore(server, stat)
+		}
+		stat.mu.Unlock()
+	}
+	d.resetNextRestore()
+}
+
+// resetNextRestore 重新计算最早到期的驱逐时间，调用方需持有 d.mu
+func (d *OutlierDetector) resetNextRestore() {
+	var next int64
+	for _, stat := range d.ejected {
+		stat.mu.Lock()
+		if until := stat.ejectedUntil.UnixNano(); next == 0 || until < next {
+			next = until
+		}
+		stat.mu.Unlock()
+	}
+	d.nextRestore.Store(next)
+}
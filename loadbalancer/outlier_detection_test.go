@@ -0,0 +1,274 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newOutlierTestDetector 创建使用固定时钟的异常检测器
+func newOutlierTestDetector(lb ServerLister, now *time.Time, opts ...OutlierDetectorOption) *OutlierDetector {
+	d := NewOutlierDetector(lb, opts...)
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+// TestOutlierConsecutiveFailures 验证连续失败后驱逐、到期恢复以及驱逐时间随次数指数增长
+func TestOutlierConsecutiveFailures(t *testing.T) {
+	servers := newTestServers(2)
+	lb := NewRoundRobinLoadBalancer(false)
+	lb.UpdateServers(servers)
+	now := time.Unix(0, 0)
+	d := newOutlierTestDetector(lb, &now, WithConsecutiveFailures(3), WithMaxEjectionPercent(50),
+		WithEjectionTime(10*time.Second, 100*time.Second))
+
+	failure := Result{Err: errors.New("connection reset")}
+	d.Record(servers[0], failure)
+	d.Record(servers[0], failure)
+	d.Record(servers[0], Result{})
+	d.Record(servers[0], failure)
+	d.Record(servers[0], failure)
+	if servers[0].Ejected() {
+		t.Fatal("成功请求已重置连续失败次数，不应驱逐")
+	}
+	d.Record(servers[0], failure)
+	if !servers[0].Ejected() {
+		t.Fatal("连续失败3次后应被驱逐")
+	}
+	for i := 0; i < 4; i++ {
+		if got := lb.GetServer(""); got != servers[1] {
+			t.Fatalf("应选中 %s，实际: %s", servers[1].Address, got.Address)
+		}
+	}
+
+	// 第一次驱逐 10s
+	now = now.Add(9 * time.Second)
+	d.evaluate(now)
+	if !servers[0].Ejected() {
+		t.Fatal("基础驱逐时间到期前不应恢复")
+	}
+	now = now.Add(time.Second)
+	d.evaluate(now)
+	if servers[0].Ejected() {
+		t.Fatal("基础驱逐时间到期后应恢复")
+	}
+
+	// 之后每次翻倍，不超过最长驱逐时间 100s
+	for _, want := range []time.Duration{20 * time.Second, 40 * time.Second, 80 * time.Second, 100 * time.Second} {
+		for i := 0; i < 3; i++ {
+			d.Record(servers[0], failure)
+		}
+		now = now.Add(want - time.Second)
+		d.evaluate(now)
+		if !servers[0].Ejected() {
+			t.Fatalf("%v 到期前不应恢复", want)
+		}
+		now = now.Add(time.Second)
+		d.evaluate(now)
+		if servers[0].Ejected() {
+			t.Fatalf("%v 到期后应恢复", want)
+		}
+	}
+}
+
+// TestOutlierConsecutiveGatewayFailures 验证连续的网关错误会驱逐服务器，其他 5xx 会打断计数
+func TestOutlierConsecutiveGatewayFailures(t *testing.T) {
+	servers := newTestServers(2)
+	lb := NewRandomLoadBalancer()
+	lb.UpdateServers(servers)
+	now := time.Unix(0, 0)
+	d := newOutlierTestDetector(lb, &now, WithConsecutiveFailures(0), WithConsecutiveGatewayFailures(3),
+		WithMaxEjectionPercent(50))
+
+	for _, code := range []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusInternalServerError,
+		http.StatusGatewayTimeout, http.StatusBadGateway} {
+		d.Record(servers[0], Result{StatusCode: code})
+	}
+	if servers[0].Ejected() {
+		t.Fatal("500 已打断连续的网关错误，不应驱逐")
+	}
+	d.Record(servers[0], Result{StatusCode: http.StatusServiceUnavailable})
+	if !servers[0].Ejected() {
+		t.Fatal("连续3次网关错误后应被驱逐")
+	}
+}
+
+// TestOutlierMaxEjectionPercent 验证驱逐比例不超过上限
+func TestOutlierMaxEjectionPercent(t *testing.T) {
+	servers := newTestServers(10)
+	lb := NewRandomLoadBalancer()
+	lb.UpdateServers(servers)
+	now := time.Unix(0, 0)
+	d := newOutlierTestDetector(lb, &now, WithConsecutiveFailures(1), WithMaxEjectionPercent(20))
+
+	for _, server := range servers {
+		d.Record(server, Result{Err: errors.New("timeout")})
+	}
+	ejected := 0
+	for _, server := range servers {
+		if server.Ejected() {
+			ejected++
+		}
+	}
+	if ejected != 2 {
+		t.Errorf("被驱逐的服务器数量应为2，实际: %d", ejected)
+	}
+}
+
+// TestOutlierSuccessRate 验证成功率明显低于其他服务器的服务器在统计周期结束时被驱逐
+func TestOutlierSuccessRate(t *testing.T) {
+	servers := newTestServers(6)
+	lb := NewRandomLoadBalancer()
+	lb.UpdateServers(servers)
+	now := time.Unix(0, 0)
+	d := newOutlierTestDetector(lb, &now, WithConsecutiveFailures(0), WithConsecutiveGatewayFailures(0),
+		WithSuccessRate(5, 100, 1.9))
+
+	for i, server := range servers {
+		for r := 0; r < 200; r++ {
+			result := Result{}
+			// servers[0] 成功率 50%，其他服务器 99%
+			if (i == 0 && r%2 == 0) || (i > 0 && r%100 == 0) {
+				result.StatusCode = http.StatusInternalServerError
+			}
+			d.Record(server, result)
+		}
+	}
+	d.evaluate(now)
+	for i, server := range servers {
+		if server.Ejected() != (i == 0) {
+			t.Errorf("第%d台服务器的驱逐状态应为%v，实际: %v", i+1, i == 0, server.Ejected())
+		}
+	}
+
+	// 请求数不足时不做成功率检测
+	now = now.Add(defaultBaseEjectionTime)
+	d.evaluate(now)
+	for r := 0; r < 10; r++ {
+		d.Record(servers[0], Result{Err: errors.New("timeout")})
+	}
+	d.evaluate(now)
+	if servers[0].Ejected() {
+		t.Error("请求数不足时不应驱逐")
+	}
+}
+
+// TestOutlierDetectorWithPick 验证通过 OnDone 接入后 Pick 回报的结果会驱逐服务器
+func TestOutlierDetectorWithPick(t *testing.T) {
+	servers := newTestServers(2)
+	lb := NewLeastConnectionsLoadBalancer(false)
+	lb.UpdateServers(servers)
+	d := NewOutlierDetector(lb, WithConsecutiveFailures(2), WithMaxEjectionPercent(50))
+	lb.OnDone(d.Record)
+	d.Start()
+	defer d.Stop()
+
+	// 暂时下线 servers[1]，使请求都落到 servers[0] 上
+	servers[1].SetStatus(StatusDisabled)
+	for i := 0; i < 2; i++ {
+		sel, err := lb.Pick(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sel.Done(Result{StatusCode: http.StatusServiceUnavailable})
+	}
+	if !servers[0].Ejected() {
+		t.Fatal("请求失败后服务器应被驱逐")
+	}
+	servers[1].SetStatus(StatusHealthy)
+	for i := 0; i < 4; i++ {
+		sel, err := lb.Pick(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sel.Server != servers[1] {
+			t.Fatalf("Pick 应选中 %s，实际: %s", servers[1].Address, sel.Server.Address)
+		}
+	}
+}
+
+// TestOutlierDetectorsShareServers 验证同一台服务器加入多个负载均衡器时，每个检测器只恢复自己驱逐的服务器
+func TestOutlierDetectorsShareServers(t *testing.T) {
+	shared := newTestServers(2)
+	a, b := NewRandomLoadBalancer(), NewRandomLoadBalancer()
+	a.UpdateServers(shared)
+	b.UpdateServers(shared)
+	now := time.Unix(0, 0)
+	da := newOutlierTestDetector(a, &now, WithConsecutiveFailures(1), WithMaxEjectionPercent(50))
+	db := newOutlierTestDetector(b, &now, WithConsecutiveFailures(1), WithMaxEjectionPercent(50),
+		WithEjectionTime(time.Minute, time.Minute))
+
+	failure := Result{Err: errors.New("timeout")}
+	da.Record(shared[0], failure)
+	db.Record(shared[0], failure)
+	if a.GetServer("") != shared[1] || b.GetServer("") != shared[1] {
+		t.Fatal("不应选中被驱逐的服务器")
+	}
+
+	// b 移除该服务器后只撤销 b 的驱逐
+	b.RemoveServerByPointer(shared[0])
+	db.evaluate(now)
+	if !shared[0].Ejected() {
+		t.Fatal("不应被其他检测器恢复")
+	}
+
+	// a 的驱逐到期后恢复
+	now = now.Add(defaultBaseEjectionTime)
+	da.evaluate(now)
+	if shared[0].Ejected() {
+		t.Fatal("所有驱逐到期后应恢复")
+	}
+
+	// 两个检测器都驱逐时，一个到期不影响另一个
+	b.AddServer(shared[0])
+	da.Record(shared[0], failure)
+	db.Record(shared[0], failure)
+	now = now.Add(2 * defaultBaseEjectionTime)
+	da.evaluate(now)
+	if !shared[0].Ejected() {
+		t.Fatal("其他检测器仍在驱逐时不应恢复")
+	}
+	now = now.Add(time.Minute)
+	db.evaluate(now)
+	if shared[0].Ejected() {
+		t.Fatal("两个驱逐都到期后应恢复")
+	}
+}
+
+// TestOutlierRestoresWithoutStart 验证没有调用 Start 时到期的驱逐在下一次 Record 时恢复
+func TestOutlierRestoresWithoutStart(t *testing.T) {
+	servers := newTestServers(2)
+	lb := NewRandomLoadBalancer()
+	lb.UpdateServers(servers)
+	now := time.Unix(0, 0)
+	d := newOutlierTestDetector(lb, &now, WithConsecutiveFailures(1), WithMaxEjectionPercent(50))
+
+	d.Record(servers[0], Result{Err: errors.New("timeout")})
+	if !servers[0].Ejected() {
+		t.Fatal("应被驱逐")
+	}
+	now = now.Add(defaultBaseEjectionTime - time.Second)
+	d.Record(servers[1], Result{})
+	if !servers[0].Ejected() {
+		t.Fatal("驱逐时间到期前不应恢复")
+	}
+	now = now.Add(time.Second)
+	d.Record(servers[1], Result{})
+	if servers[0].Ejected() {
+		t.Fatal("驱逐时间到期后 Record 应恢复服务器")
+	}
+}
+
+// TestOutlierInvalidInterval 验证非正的统计周期使用默认值，Start 不会 panic
+func TestOutlierInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		d := NewOutlierDetector(NewRandomLoadBalancer(), WithOutlierInterval(interval))
+		if d.interval != defaultOutlierInterval {
+			t.Errorf("统计周期 %v 应使用默认值，实际: %v", interval, d.interval)
+		}
+		d.Start()
+		d.Stop()
+	}
+}
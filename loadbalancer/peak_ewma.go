@@ -0,0 +1,165 @@
+package loadbalancer
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultPeakEWMADecay 默认的延迟衰减时间窗口
+	defaultPeakEWMADecay = 10 * time.Second
+	// peakEWMAPenalty 尚无延迟数据但已有在途请求时的惩罚值，避免新服务器被瞬间压垮
+	peakEWMAPenalty = float64(math.MaxInt64 >> 16)
+)
+
+// PeakEWMALoadBalancer Peak EWMA 延迟感知负载均衡器
+// 以响应延迟的指数加权移动平均乘以在途请求数作为代价，选择代价最小的服务器。
+// 观测到比当前平均值更高的延迟时立即取峰值，较低的延迟则按时间衰减平滑，
+// 因此变慢的服务器会很快被避开，恢复后再逐渐获得流量
+type PeakEWMALoadBalancer struct {
+	*BaseLoadBalancer
+	connections *connectionTracker
+	decay       time.Duration
+	// stats 服务器到延迟统计的映射，只在服务器列表变更时整体替换
+	stats atomic.Pointer[map[*Server]*ewmaStat]
+}
+
+// ewmaStat 单台服务器的延迟统计
+type ewmaStat struct {
+	mu    sync.Mutex
+	value float64 // 纳秒
+	stamp time.Time
+}
+
+// NewPeakEWMALoadBalancer 创建 Peak EWMA 负载均衡器，decay 为延迟衰减的时间窗口，
+// 小于等于0时使用默认值
+func NewPeakEWMALoadBalancer(decay time.Duration) *PeakEWMALoadBalancer {
+	if decay <= 0 {
+		decay = defaultPeakEWMADecay
+	}
+	lb := &PeakEWMALoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+		connections:      newConnectionTracker(),
+		decay:            decay,
+	}
+	lb.stats.Store(&map[*Server]*ewmaStat{})
+	lb.onChange = lb.syncStats
+	return lb
+}
+
+// GetServer 获取代价最小的服务器
+func (lb *PeakEWMALoadBalancer) GetServer(key string) *Server {
+	now := time.Now()
+	stats := *lb.stats.Load()
+	var selectedServer *Server
+	minCost := math.Inf(1)
+
+	for _, server := range lb.loadSnapshot().availableServers {
+		// 跳过探测名额已满的半开服务器，否则重试时会再次选中它
+		if server.circuitBlocked() {
+			continue
+		}
+		cost := lb.cost(server, stats[server], now)
+		if cost < minCost {
+			minCost = cost
+			selectedServer = server
+		} else if cost == minCost && selectedServer != nil && server.Weight > selectedServer.Weight {
+			// 代价相同时优先选择权重更高的服务器
+			selectedServer = server
+		}
+	}
+
+	if selectedServer != nil {
+		lb.connections.acquire(selectedServer)
+	}
+	return selectedServer
+}
+
+// Pick 选择服务器并返回选择句柄，句柄的 Done 会释放连接并记录延迟
+func (lb *PeakEWMALoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, func(server *Server, result Result) {
+		lb.ReleaseConnection(server, result.Latency)
+	})
+}
+
+// ReleaseConnection 释放连接并记录本次请求的延迟，rtt 小于等于0时只释放连接
+func (lb *PeakEWMALoadBalancer) ReleaseConnection(server *Server, rtt time.Duration) {
+	if server == nil {
+		return
+	}
+
+	lb.connections.release(server)
+	if rtt <= 0 {
+		return
+	}
+
+	if stat := (*lb.stats.Load())[server]; stat != nil {
+		stat.observe(float64(rtt), time.Now(), lb.decay)
+	}
+}
+
+// cost 计算服务器的代价
+func (lb *PeakEWMALoadBalancer) cost(server *Server, stat *ewmaStat, now time.Time) float64 {
+	outstanding := float64(lb.connections.load(server))
+
+	var latency float64
+	if stat != nil {
+		latency = stat.get(now, lb.decay)
+	}
+
+	var cost float64
+	if latency == 0 && outstanding > 0 {
+		cost = peakEWMAPenalty + outstanding
+	} else {
+		cost = latency * (outstanding + 1)
+	}
+	return cost / float64(server.Weight)
+}
+
+// syncStats 根据新的服务器列表同步延迟统计和连接计数
+func (lb *PeakEWMALoadBalancer) syncStats(servers []*Server) {
+	old := *lb.stats.Load()
+	stats := make(map[*Server]*ewmaStat, len(servers))
+	for _, server := range servers {
+		stat := old[server]
+		if stat == nil {
+			stat = &ewmaStat{}
+		}
+		stats[server] = stat
+	}
+	lb.stats.Store(&stats)
+	lb.connections.sync(servers)
+}
+
+// observe 记录一次延迟观测：高于当前值时直接取峰值，否则按经过的时间衰减平滑
+func (s *ewmaStat) observe(rtt float64, now time.Time, decay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rtt > s.value {
+		s.value = rtt
+	} else {
+		w := s.weight(now, decay)
+		s.value = s.value*w + rtt*(1-w)
+	}
+	s.stamp = now
+}
+
+// get 获取衰减到当前时间的延迟值，长时间没有观测的服务器会逐渐回落并重新获得流量
+func (s *ewmaStat) get(now time.Time, decay time.Duration) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.value * s.weight(now, decay)
+}
+
+// weight 计算旧值的衰减权重，调用方需持有锁
+func (s *ewmaStat) weight(now time.Time, decay time.Duration) float64 {
+	elapsed := now.Sub(s.stamp)
+	if s.stamp.IsZero() || elapsed <= 0 {
+		return 1
+	}
+	return math.Exp(-float64(elapsed) / float64(decay))
+}
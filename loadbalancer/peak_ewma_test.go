@@ -0,0 +1,38 @@
+package loadbalancer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeakEWMAShedsSlowServer(t *testing.T) {
+	lb := NewPeakEWMALoadBalancer(time.Minute)
+	slow := &Server{Address: "Server-Slow", Weight: 1}
+	fast := &Server{Address: "Server-Fast", Weight: 1}
+	lb.AddServer(slow)
+	lb.AddServer(fast)
+
+	// 先让两台服务器各处理一个请求，回报不同的延迟
+	first := lb.GetServer("")
+	second := lb.GetServer("")
+	if first == second {
+		t.Fatalf("没有延迟数据时应分散到不同服务器")
+	}
+	lb.ReleaseConnection(slow, 100*time.Millisecond)
+	lb.ReleaseConnection(fast, time.Millisecond)
+
+	// 快服务器即使有若干在途请求，代价仍低于慢服务器
+	for i := 0; i < 10; i++ {
+		if server := lb.GetServer(""); server != fast {
+			t.Fatalf("第%d次选择应为快服务器，实际: %s", i+1, server.Address)
+		}
+	}
+
+	// 快服务器出现更高的延迟时立即取峰值
+	for i := 0; i < 10; i++ {
+		lb.ReleaseConnection(fast, time.Second)
+	}
+	if server := lb.GetServer(""); server != slow {
+		t.Errorf("快服务器变慢后应选择另一台服务器，实际: %s", server.Address)
+	}
+}
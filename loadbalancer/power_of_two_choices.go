@@ -0,0 +1,121 @@
+package loadbalancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// p2cMaxRetries 两次采样落到同一台服务器时的最大重试次数
+const p2cMaxRetries = 3
+
+// P2COption 二选一负载均衡器的配置项
+type P2COption func(*PowerOfTwoChoicesLoadBalancer)
+
+// WithP2CRandSource 使用指定的随机数源进行采样，src 本身不需要是并发安全的
+func WithP2CRandSource(src rand.Source) P2COption {
+	return func(lb *PowerOfTwoChoicesLoadBalancer) {
+		rng := lockedRand(src)
+		lb.uint64N, lb.randFloat = rng.Uint64N, rng.Float64
+	}
+}
+
+// WithP2CSeed 使用固定种子的随机数源进行采样，相同的种子和请求序列总是得到相同的服务器序列
+func WithP2CSeed(seed uint64) P2COption {
+	return WithP2CRandSource(newSeededSource(seed))
+}
+
+// WithP2CSlowStart 为新加入的服务器启用慢启动，窗口内按逐渐增大的权重被采样，
+// 比较在途请求数时也按权重比例放大其负载，避免空闲的新服务器总是胜出
+func WithP2CSlowStart(config SlowStartConfig) P2COption {
+	return func(lb *PowerOfTwoChoicesLoadBalancer) {
+		lb.slowStart = newSlowStart(config)
+	}
+}
+
+// PowerOfTwoChoicesLoadBalancer 二选一（P2C）负载均衡器
+// 按权重随机采样两台可用服务器，选择在途请求数更少的一台
+type PowerOfTwoChoicesLoadBalancer struct {
+	*BaseLoadBalancer
+	connections *connectionTracker
+	// uint64N 返回 [0, n) 内的随机数，必须是并发安全的
+	uint64N func(n uint64) uint64
+	// randFloat 返回 [0, 1) 内的随机数，必须是并发安全的，用于慢启动期间的加权采样
+	randFloat func() float64
+	slowStart *slowStart
+}
+
+// NewPowerOfTwoChoicesLoadBalancer 创建二选一负载均衡器
+func NewPowerOfTwoChoicesLoadBalancer(opts ...P2COption) *PowerOfTwoChoicesLoadBalancer {
+	lb := &PowerOfTwoChoicesLoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+		connections:      newConnectionTracker(),
+		uint64N:          rand.Uint64N,
+		randFloat:        rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(lb)
+	}
+	lb.onChange = lb.syncConnections
+	return lb
+}
+
+// GetServer 采样两台服务器并选择在途请求数更少的一台
+func (lb *PowerOfTwoChoicesLoadBalancer) GetServer(key string) *Server {
+	snapshot := lb.loadSnapshot()
+	if len(snapshot.availableServers) == 0 {
+		return nil
+	}
+
+	// 采样会跳过探测名额已满的半开服务器，否则它没有在途请求，总会在比较中胜出
+	now, ramping := lb.slowStart.active()
+	first := lb.sample(snapshot, now, ramping)
+	if first == nil {
+		return nil
+	}
+	second := first
+	for i := 0; i < p2cMaxRetries && second == first && len(snapshot.availableServers) > 1; i++ {
+		second = lb.sample(snapshot, now, ramping)
+	}
+
+	selectedServer := first
+	if ramping {
+		// 慢启动期间负载按权重比例放大
+		firstLoad := float64(lb.connections.load(first)+1) / lb.slowStart.factor(first, now)
+		secondLoad := float64(lb.connections.load(second)+1) / lb.slowStart.factor(second, now)
+		if secondLoad < firstLoad {
+			selectedServer = second
+		}
+	} else if lb.connections.load(second) < lb.connections.load(first) {
+		selectedServer = second
+	}
+
+	lb.connections.acquire(selectedServer)
+	return selectedServer
+}
+
+// Pick 选择服务器并返回选择句柄，句柄的 Done 会释放连接
+func (lb *PowerOfTwoChoicesLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, func(server *Server, _ Result) {
+		lb.ReleaseConnection(server)
+	})
+}
+
+// ReleaseConnection 释放连接
+func (lb *PowerOfTwoChoicesLoadBalancer) ReleaseConnection(server *Server) {
+	lb.connections.release(server)
+}
+
+// sample 按权重随机选择一台熔断器允许新请求的可用服务器，慢启动期间使用调整后的权重
+func (lb *PowerOfTwoChoicesLoadBalancer) sample(snapshot *serverSnapshot, now time.Time, ramping bool) *Server {
+	if ramping {
+		return lb.slowStart.weightedServer(snapshot.availableServers, now, lb.randFloat)
+	}
+	return snapshot.weightedServer(lb.uint64N)
+}
+
+// syncConnections 根据新的服务器列表同步连接计数和慢启动状态
+func (lb *PowerOfTwoChoicesLoadBalancer) syncConnections(servers []*Server) {
+	lb.slowStart.sync(lb.GetServers(), servers)
+	lb.connections.sync(servers)
+}
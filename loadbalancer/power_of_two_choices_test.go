@@ -0,0 +1,51 @@
+package loadbalancer
+
+import "testing"
+
+// TestP2CPrefersLessLoadedServer 验证采样到的两台服务器中在途请求数更少的一台胜出
+func TestP2CPrefersLessLoadedServer(t *testing.T) {
+	lb := NewPowerOfTwoChoicesLoadBalancer(WithP2CSeed(1))
+	busy := &Server{Address: "busy:8080", Weight: 1}
+	idle := &Server{Address: "idle:8080", Weight: 1}
+	lb.UpdateServers([]*Server{busy, idle})
+	for i := 0; i < 10; i++ {
+		lb.connections.acquire(busy)
+	}
+
+	// 只有两次采样都落到 busy 时才会选中它，概率为 (1/2)^(1+p2cMaxRetries)
+	const total = 10000
+	counts := make(map[*Server]int)
+	for i := 0; i < total; i++ {
+		server := lb.GetServer("")
+		counts[server]++
+		lb.ReleaseConnection(server)
+	}
+	if share := float64(counts[busy]) / total; share > 0.09 {
+		t.Errorf("busy 的选择比例为 %.3f，期望约为 0.0625", share)
+	}
+}
+
+// TestP2CWeightedSampling 验证按权重采样：负载相同时第一次采样的服务器胜出，选择比例等于权重比例
+func TestP2CWeightedSampling(t *testing.T) {
+	lb := NewPowerOfTwoChoicesLoadBalancer(WithP2CSeed(1))
+	servers := []*Server{
+		{Address: "server1:8080", Weight: 1},
+		{Address: "server2:8080", Weight: 0},
+		{Address: "server3:8080", Weight: 3},
+	}
+	lb.UpdateServers(servers)
+
+	const total = 40000
+	counts := make(map[*Server]int)
+	for i := 0; i < total; i++ {
+		server := lb.GetServer("")
+		counts[server]++
+		lb.ReleaseConnection(server)
+	}
+	if counts[servers[1]] != 0 {
+		t.Errorf("权重为0的服务器被选中%d次", counts[servers[1]])
+	}
+	if share := float64(counts[servers[2]]) / total; share < 0.73 || share > 0.77 {
+		t.Errorf("server3 的选择比例为 %.3f，期望约为 0.75", share)
+	}
+}
@@ -1,56 +1,105 @@
 package loadbalancer
 
 import (
-	"math/rand"
+	"context"
+	"math/rand/v2"
+	"sync"
 )
 
-// RandomLoadBalancer 随机选择负载均衡器
+// RandomOption 随机选择负载均衡器的配置项
+type RandomOption func(*RandomLoadBalancer)
+
+// WithRandSource 使用指定的随机数源，通常用于注入固定种子以便复现测试结果。
+// src 本身不需要是并发安全的，负载均衡器会为其加锁；
+// 默认使用 math/rand/v2 的全局随机数源，它在每个 P 上独立生成，并发下无锁竞争
+func WithRandSource(src rand.Source) RandomOption {
+	return func(r *RandomLoadBalancer) {
+		rng := lockedRand(src)
+		r.uint64N, r.randFloat = rng.Uint64N, rng.Float64
+	}
+}
+
+// WithRandomSeed 使用固定种子的随机数源，相同的种子和请求序列总是得到相同的服务器序列
+func WithRandomSeed(seed uint64) RandomOption {
+	return WithRandSource(newSeededSource(seed))
+}
+
+// WithRandomSlowStart 为新加入的服务器启用慢启动，窗口内按逐渐增大的权重参与加权随机
+func WithRandomSlowStart(config SlowStartConfig) RandomOption {
+	return func(r *RandomLoadBalancer) {
+		r.slowStart = newSlowStart(config)
+	}
+}
+
+// RandomLoadBalancer 随机选择负载均衡器，可以被多个 goroutine 并发使用
 type RandomLoadBalancer struct {
 	*BaseLoadBalancer
-	rng *rand.Rand
+	// uint64N 返回 [0, n) 内的随机数，必须是并发安全的
+	uint64N func(n uint64) uint64
+	// randFloat 返回 [0, 1) 内的随机数，必须是并发安全的，用于慢启动期间的加权随机
+	randFloat func() float64
+	slowStart *slowStart
+}
+
+// lockedSource 加锁保护的随机数源，使非并发安全的 rand.Source 可以被共享
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+// Uint64 实现 rand.Source
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+// lockedRand 基于 src 创建并发安全的随机数生成器
+func lockedRand(src rand.Source) *rand.Rand {
+	return rand.New(&lockedSource{src: src})
+}
+
+// newSeededSource 根据种子创建随机数源
+func newSeededSource(seed uint64) rand.Source {
+	return rand.NewPCG(seed, seed)
 }
 
 // NewRandomLoadBalancer 创建随机选择负载均衡器
-func NewRandomLoadBalancer() *RandomLoadBalancer {
-	return &RandomLoadBalancer{
+func NewRandomLoadBalancer(opts ...RandomOption) *RandomLoadBalancer {
+	r := &RandomLoadBalancer{
 		BaseLoadBalancer: NewBaseLoadBalancer(),
-		rng:              rand.New(rand.NewSource(rand.Int63())),
+		uint64N:          rand.Uint64N,
+		randFloat:        rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.slowStart != nil {
+		r.onChange = func(servers []*Server) {
+			r.slowStart.sync(r.GetServers(), servers)
+		}
 	}
+	return r
 }
 
 // GetServer 随机选择一个服务器
 func (r *RandomLoadBalancer) GetServer(key string) *Server {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// 过滤出可用的服务器
-	availableServers := make([]*Server, 0)
-	for _, server := range r.Servers {
-		if server.Weight > 0 {
-			availableServers = append(availableServers, server)
-		}
-	}
-
-	if len(availableServers) == 0 {
+	// 可用服务器和别名表已在快照中预先计算
+	snapshot := r.loadSnapshot()
+	if len(snapshot.availableServers) == 0 {
 		return nil
 	}
 
-	// 计算总权重
-	totalWeight := 0
-	for _, server := range availableServers {
-		totalWeight += server.Weight
+	// 慢启动期间权重随时间变化，不能使用预先计算的别名表
+	if now, ok := r.slowStart.active(); ok {
+		return r.slowStart.weightedServer(snapshot.availableServers, now, r.randFloat)
 	}
 
 	// 随机选择一个服务器（考虑权重）
-	randomWeight := r.rng.Intn(totalWeight)
-	currentWeight := 0
-	for _, server := range availableServers {
-		currentWeight += server.Weight
-		if randomWeight < currentWeight {
-			return server
-		}
-	}
+	return snapshot.weightedServer(r.uint64N)
+}
 
-	// 如果因为浮点数精度问题没有选中任何服务器，返回最后一个
-	return availableServers[len(availableServers)-1]
+// Pick 随机选择一个服务器并返回选择句柄
+func (r *RandomLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return r.pick(ctx, key, r.GetServer, nil)
 }
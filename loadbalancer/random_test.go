@@ -0,0 +1,131 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestRandomWeightedDistribution 验证随机负载均衡器按权重分配请求
+func TestRandomWeightedDistribution(t *testing.T) {
+	lb := NewRandomLoadBalancer()
+	servers := []*Server{
+		{Address: "server1:8080", Weight: 1},
+		{Address: "server2:8080", Weight: 0},
+		{Address: "server3:8080", Weight: 3},
+	}
+	lb.UpdateServers(servers)
+
+	const total = 40000
+	counts := make(map[*Server]int)
+	for i := 0; i < total; i++ {
+		counts[lb.GetServer("")]++
+	}
+	if counts[servers[1]] != 0 {
+		t.Errorf("权重为0的服务器被选中%d次", counts[servers[1]])
+	}
+	if share := float64(counts[servers[2]]) / total; share < 0.73 || share > 0.77 {
+		t.Errorf("server3 的选择比例为 %.3f，期望约为 0.75", share)
+	}
+}
+
+// newTestServers 创建 n 台权重为1的服务器
+func newTestServers(n int) []*Server {
+	servers := make([]*Server, n)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("server%d:8080", i+1), Weight: 1}
+	}
+	return servers
+}
+
+// TestRandomSeededSource 验证注入相同种子的随机数源时选择序列可以复现
+func TestRandomSeededSource(t *testing.T) {
+	servers := newTestServers(10)
+	a := NewRandomLoadBalancer(WithRandSource(rand.NewPCG(1, 2)))
+	b := NewRandomLoadBalancer(WithRandSource(rand.NewPCG(1, 2)))
+	a.UpdateServers(servers)
+	b.UpdateServers(servers)
+
+	for i := 0; i < 1000; i++ {
+		if sa, sb := a.GetServer(""), b.GetServer(""); sa != sb {
+			t.Fatalf("相同种子第%d次选择的结果不同: %s, %s", i, sa.Address, sb.Address)
+		}
+	}
+}
+
+// TestSeededSelectionIsReproducible 验证固定种子时每次运行的选择序列完全相同，不同种子的序列不同
+func TestSeededSelectionIsReproducible(t *testing.T) {
+	servers := newTestServers(10)
+	sequences := map[string]func(seed uint64) []string{
+		"Random": func(seed uint64) []string {
+			lb := NewRandomLoadBalancer(WithRandomSeed(seed))
+			lb.UpdateServers(servers)
+			return selectionSequence(lb, nil)
+		},
+		"PowerOfTwoChoices": func(seed uint64) []string {
+			lb := NewPowerOfTwoChoicesLoadBalancer(WithP2CSeed(seed))
+			lb.UpdateServers(servers)
+			// 每隔一次释放连接，使在途请求数参与选择
+			held := 0
+			return selectionSequence(lb, func(server *Server) {
+				if held++; held%2 == 0 {
+					lb.ReleaseConnection(server)
+				}
+			})
+		},
+	}
+
+	for name, sequence := range sequences {
+		first, second, other := sequence(42), sequence(42), sequence(43)
+		if !slices.Equal(first, second) {
+			t.Errorf("%s: 相同种子的选择序列不同", name)
+		}
+		if slices.Equal(first, other) {
+			t.Errorf("%s: 不同种子的选择序列相同", name)
+		}
+	}
+}
+
+// selectionSequence 连续选择若干次并返回服务器地址序列，after 在每次选择后调用，可以为nil
+func selectionSequence(selector Selector, after func(server *Server)) []string {
+	sequence := make([]string, 200)
+	for i := range sequence {
+		server := selector.GetServer("")
+		sequence[i] = server.Address
+		if after != nil {
+			after(server)
+		}
+	}
+	return sequence
+}
+
+// TestRandomConcurrentGetServer 并发选择服务器，配合 go test -race 验证没有数据竞争
+func TestRandomConcurrentGetServer(t *testing.T) {
+	for _, lb := range []*RandomLoadBalancer{
+		NewRandomLoadBalancer(),
+		NewRandomLoadBalancer(WithRandSource(rand.NewPCG(1, 2))),
+	} {
+		lb.UpdateServers(newTestServers(10))
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 1000; i++ {
+					if lb.GetServer("") == nil {
+						t.Error("GetServer 返回了nil")
+						return
+					}
+				}
+			}()
+		}
+		// 同时变更服务器列表
+		for i := 0; i < 100; i++ {
+			lb.UpdateServers(newTestServers(1 + i%10))
+		}
+		wg.Wait()
+	}
+}
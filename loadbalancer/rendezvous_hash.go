@@ -0,0 +1,83 @@
+package loadbalancer
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+
+	"github.com/zeebo/xxh3"
+)
+
+// rendezvousNode 参与打分的服务器
+type rendezvousNode struct {
+	server *Server
+	// seed 服务器地址的哈希值，作为计算key得分时的种子
+	seed   uint64
+	weight float64
+}
+
+// RendezvousHashLoadBalancer 加权 Rendezvous（最高随机权重，HRW）哈希负载均衡器
+// 对每个key计算所有服务器的得分 -weight / ln(h)，h 为 (0,1) 区间内均匀分布的哈希值，
+// 选择得分最高的服务器。这种对数加权方式保证每台服务器被选中的概率严格等于其权重占比，
+// 服务器变更时只有原本映射到变更服务器的key会被重新映射。
+// 查找为 O(n)，不需要构建查找表，适合服务器数量较少的场景
+type RendezvousHashLoadBalancer struct {
+	*BaseLoadBalancer
+	nodes atomic.Pointer[[]rendezvousNode]
+}
+
+// NewRendezvousHashLoadBalancer 创建 Rendezvous 哈希负载均衡器
+func NewRendezvousHashLoadBalancer() *RendezvousHashLoadBalancer {
+	lb := &RendezvousHashLoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+	}
+	lb.nodes.Store(&[]rendezvousNode{})
+	lb.onChange = lb.updateNodes
+	return lb
+}
+
+// GetServer 根据key获取得分最高的服务器
+func (lb *RendezvousHashLoadBalancer) GetServer(key string) *Server {
+	var selectedServer *Server
+	maxScore := math.Inf(-1)
+	for _, node := range *lb.nodes.Load() {
+		// 不可用的服务器在查找时跳过，其他key的映射保持不变
+		if !node.server.Available() {
+			continue
+		}
+		score := rendezvousScore(key, node)
+		if score > maxScore {
+			maxScore = score
+			selectedServer = node.server
+		}
+	}
+	return selectedServer
+}
+
+// Pick 根据key选择服务器并返回选择句柄
+func (lb *RendezvousHashLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, nil)
+}
+
+// updateNodes 根据新的服务器列表重建参与打分的服务器
+func (lb *RendezvousHashLoadBalancer) updateNodes(servers []*Server) {
+	nodes := make([]rendezvousNode, 0, len(servers))
+	for _, server := range servers {
+		if server.Weight > 0 { // 只考虑权重大于0的服务器为可用
+			nodes = append(nodes, rendezvousNode{
+				server: server,
+				seed:   xxh3.HashString(server.Identity()),
+				weight: float64(server.Weight),
+			})
+		}
+	}
+	lb.nodes.Store(&nodes)
+}
+
+// rendezvousScore 计算key在服务器上的得分
+func rendezvousScore(key string, node rendezvousNode) float64 {
+	hash := xxh3.HashStringSeed(key, node.seed)
+	// 取高53位映射到 (0,1) 开区间，避免 ln(0) 和 ln(1)
+	u := (float64(hash>>11) + 0.5) / (1 << 53)
+	return -node.weight / math.Log(u)
+}
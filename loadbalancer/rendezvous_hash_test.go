@@ -0,0 +1,48 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestRendezvousHashDistribution(t *testing.T) {
+	lb := NewRendezvousHashLoadBalancer()
+	servers := []*Server{
+		{Address: "Server-A", Weight: 1},
+		{Address: "Server-B", Weight: 2},
+		{Address: "Server-C", Weight: 3},
+		{Address: "Server-D", Weight: 4},
+	}
+	for _, server := range servers {
+		lb.AddServer(server)
+	}
+
+	const keyCount = 100000
+	assignments := make(map[string]*Server, keyCount)
+	counts := make(map[*Server]int)
+	for i := 0; i < keyCount; i++ {
+		key := fmt.Sprintf("key%d", i)
+		server := lb.GetServer(key)
+		assignments[key] = server
+		counts[server]++
+	}
+
+	// 分布应与权重比例一致
+	for _, server := range servers {
+		expected := float64(server.Weight) / 10
+		actual := float64(counts[server]) / keyCount
+		if math.Abs(actual-expected) > 0.01 {
+			t.Errorf("%s 的占比应约为%.2f，实际: %.4f", server.Address, expected, actual)
+		}
+	}
+
+	// 移除服务器时只有原本映射到该服务器的key被重新映射
+	lb.RemoveServerByPointer(servers[1])
+	for key, before := range assignments {
+		after := lb.GetServer(key)
+		if before != servers[1] && after != before {
+			t.Fatalf("key %s 不应被重新映射: %s -> %s", key, before.Address, after.Address)
+		}
+	}
+}
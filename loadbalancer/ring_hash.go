@@ -0,0 +1,183 @@
+package loadbalancer
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	// ketamaPointsPerServer libketama 中平均每台服务器的 md5 摘要个数
+	ketamaPointsPerServer = 40
+	// ketamaPointsPerHash 每个 md5 摘要切分出的虚拟节点个数
+	ketamaPointsPerHash = 4
+)
+
+// RingHashOption 环哈希负载均衡器的配置项
+type RingHashOption func(*RingHashLoadBalancer)
+
+// WithRingSize 设置哈希环虚拟节点总数的上下限，为0表示不限制，下限大于上限时忽略该配置。
+// 默认按 libketama 的规则生成虚拟节点（平均每台服务器160个），需要缩放时按比例调整每台服务器的虚拟节点数，
+// 每台服务器至少保留一个虚拟节点，因此服务器很多时总数可能超过上限。
+// 发生缩放后虚拟节点与 libketama 不同，不再与 ketama 客户端兼容
+func WithRingSize(minSize, maxSize int) RingHashOption {
+	return func(lb *RingHashLoadBalancer) {
+		if minSize > 0 && maxSize > 0 && minSize > maxSize {
+			return
+		}
+		lb.minRingSize = minSize
+		lb.maxRingSize = maxSize
+	}
+}
+
+// WithRingHashFunc 设置虚拟节点和key使用的哈希函数，
+// 默认为 libketama 的 md5 方案，设置后不再与 ketama 客户端兼容
+func WithRingHashFunc(fn HashFunc) RingHashOption {
+	return func(lb *RingHashLoadBalancer) {
+		lb.hashFunc = fn
+	}
+}
+
+// ringPoint 哈希环上的一个虚拟节点
+type ringPoint struct {
+	hash   uint64
+	server *Server
+}
+
+// RingHashLoadBalancer Ketama 环哈希负载均衡器
+// 每台服务器按权重比例在哈希环上放置虚拟节点，key 映射到顺时针方向的第一个虚拟节点。
+// 默认配置下虚拟节点的生成方式与 libketama 一致，可以与使用 ketama 的 memcached 客户端互通
+type RingHashLoadBalancer struct {
+	*BaseLoadBalancer
+	ring        atomic.Pointer[[]ringPoint]
+	hashFunc    HashFunc
+	minRingSize int
+	maxRingSize int
+}
+
+// NewRingHashLoadBalancer 创建环哈希负载均衡器
+func NewRingHashLoadBalancer(opts ...RingHashOption) *RingHashLoadBalancer {
+	lb := &RingHashLoadBalancer{
+		BaseLoadBalancer: NewBaseLoadBalancer(),
+	}
+	for _, opt := range opts {
+		opt(lb)
+	}
+	lb.ring.Store(&[]ringPoint{})
+	lb.onChange = lb.updateRing
+	return lb
+}
+
+// GetServer 根据key获取服务器
+func (lb *RingHashLoadBalancer) GetServer(key string) *Server {
+	ring := *lb.ring.Load()
+	if len(ring) == 0 {
+		return nil
+	}
+
+	index := searchRing(ring, lb.hashKey(keyBytes(key)))
+	// 超过环上最大的点时回到起点，不可用的服务器顺时针跳过
+	for i := 0; i < len(ring); i++ {
+		if server := ring[(index+i)%len(ring)].server; server.Available() {
+			return server
+		}
+	}
+	return nil
+}
+
+// Pick 根据key选择服务器并返回选择句柄
+func (lb *RingHashLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, nil)
+}
+
+// updateRing 重建哈希环
+func (lb *RingHashLoadBalancer) updateRing(servers []*Server) {
+	// 筛选可用的服务器
+	availableServers := make([]*Server, 0, len(servers))
+	totalWeight := 0
+	for _, server := range servers {
+		if server.Weight > 0 {
+			availableServers = append(availableServers, server)
+			totalWeight += server.Weight
+		}
+	}
+
+	if len(availableServers) == 0 {
+		lb.ring.Store(&[]ringPoint{})
+		return
+	}
+
+	// 与 libketama 的 floorf(pct * 40.0 * numservers) 保持一致，pct 使用 float32 计算
+	counts := make([]int, len(availableServers))
+	totalPoints := 0
+	for i, server := range availableServers {
+		pct := float32(server.Weight) / float32(totalWeight)
+		ks := float32(float64(pct) * ketamaPointsPerServer * float64(len(availableServers)))
+		counts[i] = int(math.Floor(float64(ks)))
+		totalPoints += counts[i] * ketamaPointsPerHash
+	}
+
+	// 按上下限缩放虚拟节点总数
+	scale := 1.0
+	if lb.minRingSize > 0 && totalPoints < lb.minRingSize {
+		scale = float64(lb.minRingSize) / float64(totalPoints)
+	}
+	if lb.maxRingSize > 0 && float64(totalPoints)*scale > float64(lb.maxRingSize) {
+		scale = float64(lb.maxRingSize) / float64(totalPoints)
+	}
+
+	ring := make([]ringPoint, 0, int(float64(totalPoints)*scale)+len(availableServers))
+	for i, server := range availableServers {
+		// 每台有权重的服务器至少保留一个虚拟节点，否则缩放或权重悬殊时服务器会从环上消失。
+		// 这与 libketama 不同：libketama 会让权重不足平均值1/40的服务器没有虚拟节点
+		points := max(1, int(float64(counts[i]*ketamaPointsPerHash)*scale))
+		if lb.hashFunc == nil {
+			// ketama：每个 md5 摘要切分出4个点，缩放后最后一个摘要可能只取部分点
+			for k := 0; k*ketamaPointsPerHash < points; k++ {
+				digest := md5.Sum([]byte(server.Identity() + "-" + strconv.Itoa(k)))
+				for h := 0; h < ketamaPointsPerHash && k*ketamaPointsPerHash+h < points; h++ {
+					point := binary.LittleEndian.Uint32(digest[h*4 : h*4+4])
+					ring = append(ring, ringPoint{hash: uint64(point), server: server})
+				}
+			}
+			continue
+		}
+
+		for k := 0; k < points; k++ {
+			hash := lb.hashFunc([]byte(server.Identity() + "-" + strconv.Itoa(k)))
+			ring = append(ring, ringPoint{hash: hash, server: server})
+		}
+	}
+
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	lb.ring.Store(&ring)
+}
+
+// searchRing 二分查找第一个哈希值不小于 hash 的虚拟节点，不存在时返回 len(ring)
+func searchRing(ring []ringPoint, hash uint64) int {
+	low, high := 0, len(ring)
+	for low < high {
+		mid := int(uint(low+high) >> 1)
+		if ring[mid].hash < hash {
+			low = mid + 1
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
+
+// hashKey 计算key在哈希环上的位置
+func (lb *RingHashLoadBalancer) hashKey(key []byte) uint64 {
+	if lb.hashFunc == nil {
+		digest := md5.Sum(key)
+		return uint64(binary.LittleEndian.Uint32(digest[:4]))
+	}
+	return lb.hashFunc(key)
+}
@@ -0,0 +1,166 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingHashKetamaPoints(t *testing.T) {
+	lb := NewRingHashLoadBalancer()
+	servers := []*Server{
+		{Address: "10.0.0.1:11211", Weight: 1},
+		{Address: "10.0.0.2:11211", Weight: 1},
+		{Address: "10.0.0.3:11211", Weight: 2},
+	}
+	for _, server := range servers {
+		lb.AddServer(server)
+	}
+
+	// libketama：floor(pct * 40 * 3) 个摘要，每个摘要4个点
+	counts := make(map[*Server]int)
+	for _, point := range *lb.ring.Load() {
+		counts[point.server]++
+	}
+	expected := []int{120, 120, 240}
+	for i, server := range servers {
+		if counts[server] != expected[i] {
+			t.Errorf("%s 的虚拟节点数应为%d，实际: %d", server.Address, expected[i], counts[server])
+		}
+	}
+
+	// 权重相同时每台服务器的虚拟节点固定为160个，移除服务器只会影响映射到该服务器的key
+	lb = NewRingHashLoadBalancer()
+	servers[2].Weight = 1
+	for _, server := range servers {
+		lb.AddServer(server)
+	}
+	keys := make(map[string]*Server)
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		keys[key] = lb.GetServer(key)
+	}
+	lb.RemoveServerByPointer(servers[0])
+	for key, before := range keys {
+		if after := lb.GetServer(key); before != servers[0] && after != before {
+			t.Errorf("key %s 不应被重新映射: %s -> %s", key, before.Address, after.Address)
+		}
+	}
+}
+
+// TestRingHashKetamaGolden 与 libketama 的结果逐项对比：
+// 虚拟节点为 md5("host:port-k") 按小端切分的4个 uint32，key 的位置为 md5(key) 的前4字节，
+// 期望值按 libketama 的 ketama_create_continuum 和 ketama_get_server 独立计算
+func TestRingHashKetamaGolden(t *testing.T) {
+	lb := NewRingHashLoadBalancer()
+	servers := []*Server{
+		{Address: "10.0.0.1:11211", Weight: 1},
+		{Address: "10.0.0.2:11211", Weight: 1},
+		{Address: "10.0.0.3:11211", Weight: 2},
+	}
+	lb.UpdateServers(servers)
+
+	points := make(map[uint64]*Server)
+	for _, point := range *lb.ring.Load() {
+		points[point.hash] = point.server
+	}
+	goldenPoints := []struct {
+		server *Server
+		hashes []uint64
+	}{
+		// md5("10.0.0.1:11211-0")
+		{servers[0], []uint64{1644766326, 266575842, 1549369152, 2004188753}},
+		// md5("10.0.0.3:11211-59")，权重为2的服务器有 floor(0.5*40*3)=60 个摘要
+		{servers[2], []uint64{2509527321, 2988307325, 1448639233, 2836864361}},
+		// md5("10.0.0.3:11211-60") 超出摘要个数，不在环上
+		{nil, []uint64{1890272636, 2255765054, 3572705527, 3493417157}},
+	}
+	for _, golden := range goldenPoints {
+		for _, hash := range golden.hashes {
+			if points[hash] != golden.server {
+				t.Errorf("虚拟节点 %d 应属于 %v，实际: %v", hash, golden.server, points[hash])
+			}
+		}
+	}
+
+	goldenKeys := []struct {
+		key    string
+		hash   uint64
+		server *Server
+	}{
+		{"foo", 3675831724, servers[2]},
+		{"bar", 421377335, servers[0]},
+		{"baz", 2768240243, servers[2]},
+		{"memcached", 1357326829, servers[2]},
+		{"user:1000", 781738503, servers[1]},
+		{"session:abc", 226189362, servers[2]},
+		{"key0", 4060279841, servers[2]},
+		{"key42", 3628181931, servers[2]},
+	}
+	for _, golden := range goldenKeys {
+		if hash := lb.hashKey([]byte(golden.key)); hash != golden.hash {
+			t.Errorf("key %q 的哈希值应为%d，实际: %d", golden.key, golden.hash, hash)
+		}
+		if server := lb.GetServer(golden.key); server != golden.server {
+			t.Errorf("key %q 应映射到 %s，实际: %s", golden.key, golden.server.Address, server.Address)
+		}
+	}
+}
+
+func TestRingHashRingSizeBounds(t *testing.T) {
+	lb := NewRingHashLoadBalancer(WithRingSize(1024, 2048), WithRingHashFunc(XXH3Hash))
+	lb.AddServer(&Server{Address: "10.0.0.1:11211", Weight: 1})
+	if len(*lb.ring.Load()) != 1024 {
+		t.Errorf("虚拟节点总数应被提升到下限1024，实际: %d", len(*lb.ring.Load()))
+	}
+
+	for i := 2; i <= 20; i++ {
+		lb.AddServer(&Server{Address: fmt.Sprintf("10.0.0.%d:11211", i), Weight: 1})
+	}
+	if len(*lb.ring.Load()) > 2048 {
+		t.Errorf("虚拟节点总数不应超过上限2048，实际: %d", len(*lb.ring.Load()))
+	}
+
+}
+
+func TestRingHashScalingKeepsEveryServer(t *testing.T) {
+	// 上限远小于自然大小时每台服务器仍保留虚拟节点
+	lb := NewRingHashLoadBalancer(WithRingSize(0, 100))
+	servers := make([]*Server, 50)
+	for i := range servers {
+		servers[i] = &Server{Address: fmt.Sprintf("10.0.1.%d:11211", i), Weight: 1}
+	}
+	lb.UpdateServers(servers)
+	counts := make(map[*Server]int)
+	for _, point := range *lb.ring.Load() {
+		counts[point.server]++
+	}
+	for _, server := range servers {
+		if counts[server] != 2 {
+			t.Errorf("%s 的虚拟节点数应为2，实际: %d", server.Address, counts[server])
+		}
+	}
+	if lb.GetServer("key") == nil {
+		t.Error("缩放后的哈希环不应为空")
+	}
+
+	// 权重悬殊时低权重服务器也按比例保留虚拟节点，而不是按摘要取整后被舍去
+	lb = NewRingHashLoadBalancer(WithRingSize(0, 100))
+	light := &Server{Address: "10.0.2.1:11211", Weight: 1}
+	heavy := &Server{Address: "10.0.2.2:11211", Weight: 30}
+	lb.UpdateServers([]*Server{light, heavy})
+	counts = make(map[*Server]int)
+	for _, point := range *lb.ring.Load() {
+		counts[point.server]++
+	}
+	// 自然大小为 floor(1/31*80)*4=8 和 floor(30/31*80)*4=308，按 100/316 缩放
+	if counts[light] != 2 || counts[heavy] != 97 {
+		t.Errorf("虚拟节点数应为2和97，实际: %d和%d", counts[light], counts[heavy])
+	}
+
+	// 下限大于上限时忽略该配置，按 libketama 的规则生成
+	lb = NewRingHashLoadBalancer(WithRingSize(2048, 1024))
+	lb.AddServer(&Server{Address: "10.0.3.1:11211", Weight: 1})
+	if len(*lb.ring.Load()) != 160 {
+		t.Errorf("下限大于上限时虚拟节点总数应为160，实际: %d", len(*lb.ring.Load()))
+	}
+}
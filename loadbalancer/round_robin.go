@@ -1,96 +1,238 @@
 package loadbalancer
 
 import (
+	"context"
+	"math"
+	"sync"
 	"sync/atomic"
 )
 
+const (
+	// defaultMaxFails 默认的 maxFails，与 nginx 的 max_fails 默认值一致
+	defaultMaxFails = 1
+	// slowStartWeightScale 慢启动期间权重的放大倍数，使较小的整数权重也能按比例缩小
+	slowStartWeightScale = 100
+)
+
+// RoundRobinOption 轮询负载均衡器的配置项
+type RoundRobinOption func(*RoundRobinLoadBalancer)
+
+// WithMaxFails 设置加权轮询中有效权重降到0所需的连续失败次数，
+// 每次失败有效权重减少 Weight/maxFails，与 nginx 的 max_fails 含义相同，默认为1
+func WithMaxFails(maxFails int) RoundRobinOption {
+	return func(lb *RoundRobinLoadBalancer) {
+		if maxFails > 0 {
+			lb.maxFails = maxFails
+		}
+	}
+}
+
+// WithRoundRobinSlowStart 为新加入的服务器启用慢启动，只对加权轮询生效
+func WithRoundRobinSlowStart(config SlowStartConfig) RoundRobinOption {
+	return func(lb *RoundRobinLoadBalancer) {
+		lb.slowStart = newSlowStart(config)
+	}
+}
+
 // RoundRobinLoadBalancer 轮询负载均衡器
 type RoundRobinLoadBalancer struct {
 	*BaseLoadBalancer
 	currentIndex int64
 	weighted     bool
+	maxFails     int
+	slowStart    *slowStart
+	// wrrMu 保护平滑加权轮询的调度状态，该算法每次选择都要更新所有服务器的当前权重，
+	// 只能串行执行；非加权轮询不需要加锁
+	wrrMu sync.Mutex
+	// wrrPeers 参与平滑加权轮询的服务器及其调度状态，顺序与服务器列表一致
+	wrrPeers []*wrrPeer
+	// wrrIndex 服务器到调度状态的映射
+	wrrIndex map[*Server]*wrrPeer
+	// wrrRamping 上一次选择时是否处于慢启动期间，进出慢启动时权重的放大倍数不同，需要重置当前权重
+	wrrRamping bool
+}
+
+// wrrPeer 单台服务器在本负载均衡器中的平滑加权轮询状态。
+// 调度状态保存在负载均衡器内部而不是 Server 上，同一个 Server 可以同时加入多个负载均衡器
+type wrrPeer struct {
+	server *Server
+	// current 当前权重
+	current int
+	// effective 有效权重
+	effective int
 }
 
 // NewRoundRobinLoadBalancer 创建轮询负载均衡器
-func NewRoundRobinLoadBalancer(weighted bool) *RoundRobinLoadBalancer {
-	return &RoundRobinLoadBalancer{
+func NewRoundRobinLoadBalancer(weighted bool, opts ...RoundRobinOption) *RoundRobinLoadBalancer {
+	lb := &RoundRobinLoadBalancer{
 		BaseLoadBalancer: NewBaseLoadBalancer(),
 		weighted:         weighted,
+		maxFails:         defaultMaxFails,
+		wrrIndex:         make(map[*Server]*wrrPeer),
+	}
+	for _, opt := range opts {
+		opt(lb)
 	}
+	lb.onChange = lb.syncPeers
+	return lb
 }
 
 // GetServer 获取下一个服务器
 func (lb *RoundRobinLoadBalancer) GetServer(key string) *Server {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
-
-	if len(lb.Servers) == 0 {
+	if !lb.weighted {
+		// 非加权轮询，只在可用的服务器之间轮转
+		servers := lb.loadSnapshot().availableServers
+		if len(servers) == 0 {
+			return nil
+		}
+		index := int(atomic.AddInt64(&lb.currentIndex, 1) % int64(len(servers)))
+		// 跳过探测名额已满的半开服务器
+		for i := range servers {
+			if server := servers[(index+i)%len(servers)]; !server.circuitBlocked() {
+				return server
+			}
+		}
 		return nil
 	}
 
-	if !lb.weighted {
-		// 非加权轮询
-		index := atomic.AddInt64(&lb.currentIndex, 1) % int64(len(lb.Servers))
-		return lb.Servers[index]
-	}
+	lb.wrrMu.Lock()
+	defer lb.wrrMu.Unlock()
 
 	// 调试输出
 	/*
 		fmt.Println("当前服务器权重状态:")
-		for i, server := range lb.Servers {
+		for i, peer := range lb.wrrPeers {
 			fmt.Printf("Server%d[%s] 当前权重:%d, 有效权重:%d\n",
-				i+1, server.Address, server.CurrentWeight, server.EffectiveWeight)
+				i+1, peer.server.Address, peer.current, peer.effective)
 		}
 	*/
 
 	// 实现平滑加权轮询（Smooth Weighted Round-Robin）
 	totalWeight := 0
-	var bestServer *Server
+	var best *wrrPeer
+	now, ramping := lb.slowStart.active()
+	if ramping != lb.wrrRamping {
+		for _, peer := range lb.wrrPeers {
+			peer.current = 0
+		}
+		lb.wrrRamping = ramping
+	}
 
 	// 计算总有效权重，并为每个服务器增加当前权重
-	for _, server := range lb.Servers {
-		// 确保有效权重被初始化
-		if server.EffectiveWeight == 0 {
-			server.EffectiveWeight = server.Weight
+	for _, peer := range lb.wrrPeers {
+		// 与 nginx 一样跳过不可用的服务器
+		if !peer.server.Available() {
+			continue
+		}
+		// 当前权重增加有效权重，慢启动期间按比例缩小
+		weight := peer.effective
+		if ramping && weight > 0 {
+			weight = max(1, int(math.Round(float64(weight*slowStartWeightScale)*lb.slowStart.factor(peer.server, now))))
+		}
+		peer.current += weight
+		totalWeight += weight
+		// 因失败而降低的有效权重在每次选择时逐步恢复
+		if peer.effective < peer.server.Weight {
+			peer.effective++
 		}
-		// 当前权重增加有效权重
-		server.CurrentWeight += server.EffectiveWeight
-		totalWeight += server.EffectiveWeight
 
 		// 选择当前权重最大的服务器
-		if bestServer == nil || server.CurrentWeight > bestServer.CurrentWeight {
-			bestServer = server
+		if best == nil || peer.current > best.current {
+			best = peer
 		}
 	}
 
 	// 如果找到了最佳服务器，减少其当前权重
-	if bestServer != nil {
-		bestServer.CurrentWeight -= totalWeight
+	if best == nil {
+		return nil
+	}
+	best.current -= totalWeight
+	return best.server
+}
+
+// ReportResult 回报一次请求的结果，只对加权轮询生效：
+// 失败时有效权重减少 Weight/maxFails（最低为0），成功时恢复1（最高为 Weight）。
+// 有效权重较低的服务器获得的流量相应减少，但不会被完全摘除
+func (lb *RoundRobinLoadBalancer) ReportResult(server *Server, err error) {
+	if !lb.weighted {
+		return
+	}
+
+	lb.wrrMu.Lock()
+	defer lb.wrrMu.Unlock()
+
+	peer := lb.wrrIndex[server]
+	if peer == nil {
+		return
+	}
+
+	if err != nil {
+		peer.effective -= peer.server.Weight / lb.maxFails
+		if peer.effective < 0 {
+			peer.effective = 0
+		}
+		return
+	}
+	if peer.effective < peer.server.Weight {
+		peer.effective++
 	}
+}
 
-	return bestServer
+// Weights 获取服务器在平滑加权轮询中的当前权重和有效权重，服务器不在列表中时 ok 为false
+func (lb *RoundRobinLoadBalancer) Weights(server *Server) (current, effective int, ok bool) {
+	lb.wrrMu.Lock()
+	defer lb.wrrMu.Unlock()
+
+	peer := lb.wrrIndex[server]
+	if peer == nil {
+		return 0, 0, false
+	}
+	return peer.current, peer.effective, true
 }
 
 // ResetWeights 重置所有服务器的权重
 func (lb *RoundRobinLoadBalancer) ResetWeights() {
-	lb.mu.Lock()
-	defer lb.mu.Unlock()
+	lb.wrrMu.Lock()
+	defer lb.wrrMu.Unlock()
 
 	// 重置所有服务器的权重
-	for _, server := range lb.Servers {
-		server.CurrentWeight = 0
-		server.EffectiveWeight = server.Weight
+	for _, peer := range lb.wrrPeers {
+		peer.current = 0
+		peer.effective = peer.server.Weight
 	}
 
 	// 重置轮询状态
 	atomic.StoreInt64(&lb.currentIndex, 0)
 }
 
-// AddServer 添加服务器
-func (lb *RoundRobinLoadBalancer) AddServer(server *Server) {
-	// 只有在未设置的情况下初始化EffectiveWeight
-	if server.EffectiveWeight == 0 {
-		server.EffectiveWeight = server.Weight
+// syncPeers 根据新的服务器列表同步调度状态和慢启动状态，保留仍在列表中的服务器的状态
+func (lb *RoundRobinLoadBalancer) syncPeers(servers []*Server) {
+	lb.wrrMu.Lock()
+	defer lb.wrrMu.Unlock()
+
+	lb.slowStart.sync(lb.GetServers(), servers)
+
+	peers := make([]*wrrPeer, 0, len(servers))
+	index := make(map[*Server]*wrrPeer, len(servers))
+	for _, server := range servers {
+		peer := lb.wrrIndex[server]
+		if peer == nil {
+			peer = &wrrPeer{server: server, effective: server.Weight}
+		}
+		peers = append(peers, peer)
+		index[server] = peer
 	}
-	lb.BaseLoadBalancer.AddServer(server)
+	lb.wrrPeers = peers
+	lb.wrrIndex = index
+}
+
+// Pick 轮询选择一个服务器并返回选择句柄，句柄的 Done 会通过 ReportResult 回报结果
+func (lb *RoundRobinLoadBalancer) Pick(ctx context.Context, key string) (*Selection, error) {
+	return lb.pick(ctx, key, lb.GetServer, func(server *Server, result Result) {
+		// 被熔断器拒绝的选择不是服务器的失败
+		if result.Err == ErrCircuitOpen {
+			return
+		}
+		lb.ReportResult(server, result.Err)
+	})
 }
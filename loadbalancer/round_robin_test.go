@@ -1,6 +1,8 @@
 package loadbalancer
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
 )
@@ -11,9 +13,9 @@ func TestWeightedRoundRobin(t *testing.T) {
 
 	// 添加3个权重不同的服务器
 	servers := []*Server{
-		{Address: "Server-A", Weight: 3, EffectiveWeight: 3},
-		{Address: "Server-B", Weight: 2, EffectiveWeight: 2},
-		{Address: "Server-C", Weight: 1, EffectiveWeight: 1},
+		{Address: "Server-A", Weight: 3},
+		{Address: "Server-B", Weight: 2},
+		{Address: "Server-C", Weight: 1},
 	}
 
 	for _, server := range servers {
@@ -79,3 +81,87 @@ func TestWeightedRoundRobin(t *testing.T) {
 	// 重置服务器权重
 	lb.ResetWeights()
 }
+
+// TestWeightedRoundRobinSharedServers 验证同一组服务器加入多个加权轮询负载均衡器时调度互不影响
+func TestWeightedRoundRobinSharedServers(t *testing.T) {
+	servers := []*Server{
+		{Address: "Server-A", Weight: 3},
+		{Address: "Server-B", Weight: 2},
+		{Address: "Server-C", Weight: 1},
+	}
+	first := NewRoundRobinLoadBalancer(true)
+	second := NewRoundRobinLoadBalancer(true)
+	first.UpdateServers(servers)
+	second.UpdateServers(servers)
+
+	// 平滑加权轮询对权重[3,2,1]的确定序列
+	want := []string{"Server-A", "Server-B", "Server-A", "Server-C", "Server-B", "Server-A"}
+	for round := 0; round < 2; round++ {
+		for i, address := range want {
+			// 交替从两个负载均衡器选择，各自的序列都应保持不变
+			if got := first.GetServer("").Address; got != address {
+				t.Fatalf("第一个负载均衡器第%d次应选中 %s，实际: %s", i+1, address, got)
+			}
+			if got := second.GetServer("").Address; got != address {
+				t.Fatalf("第二个负载均衡器第%d次应选中 %s，实际: %s", i+1, address, got)
+			}
+		}
+	}
+
+	if current, effective, ok := first.Weights(servers[0]); !ok || current != 0 || effective != 3 {
+		t.Errorf("Server-A 的权重应为 0, 3, true，实际: %d, %d, %v", current, effective, ok)
+	}
+}
+
+// TestWeightedRoundRobinReportResult 验证失败降低有效权重、成功和后续选择逐步恢复有效权重
+func TestWeightedRoundRobinReportResult(t *testing.T) {
+	servers := []*Server{
+		{Address: "Server-A", Weight: 4},
+		{Address: "Server-B", Weight: 4},
+	}
+	lb := NewRoundRobinLoadBalancer(true, WithMaxFails(2))
+	lb.UpdateServers(servers)
+
+	lb.ReportResult(servers[0], errors.New("connection refused"))
+	if _, effective, _ := lb.Weights(servers[0]); effective != 2 {
+		t.Fatalf("失败一次后有效权重应为2，实际: %d", effective)
+	}
+	lb.ReportResult(servers[0], errors.New("connection refused"))
+	lb.ReportResult(servers[0], errors.New("connection refused"))
+	if _, effective, _ := lb.Weights(servers[0]); effective != 0 {
+		t.Fatalf("失败三次后有效权重应为0，实际: %d", effective)
+	}
+
+	lb.ReportResult(servers[0], nil)
+	if _, effective, _ := lb.Weights(servers[0]); effective != 1 {
+		t.Fatalf("成功一次后有效权重应为1，实际: %d", effective)
+	}
+
+	// 恢复期间故障服务器获得的流量少于正常服务器，每次选择有效权重恢复1，
+	// 有效权重依次为 1,2,3 时的选择序列为 B,A,B
+	want := []*Server{servers[1], servers[0], servers[1]}
+	for i, server := range want {
+		if got := lb.GetServer(""); got != server {
+			t.Fatalf("恢复期间第%d次应选中 %s，实际: %s", i+1, server.Address, got.Address)
+		}
+	}
+	if _, effective, _ := lb.Weights(servers[0]); effective != 4 {
+		t.Errorf("恢复后有效权重应为4，实际: %d", effective)
+	}
+}
+
+// TestRoundRobinPickReportsResult 验证 Pick 返回的句柄在 Done 时回报请求结果
+func TestRoundRobinPickReportsResult(t *testing.T) {
+	server := &Server{Address: "Server-A", Weight: 2}
+	lb := NewRoundRobinLoadBalancer(true)
+	lb.AddServer(server)
+
+	sel, err := lb.Pick(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sel.Done(Result{Err: errors.New("timeout")})
+	if _, effective, _ := lb.Weights(server); effective != 0 {
+		t.Errorf("请求失败后有效权重应为0，实际: %d", effective)
+	}
+}
@@ -0,0 +1,141 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoAvailableServer 没有可用的服务器
+var ErrNoAvailableServer = errors.New("loadbalancer: no available server")
+
+// Result 一次请求的完成结果，通过 Selection.Done 回报
+type Result struct {
+	// Err 请求失败时的错误，为nil表示成功
+	Err error
+	// StatusCode HTTP 等协议的响应状态码，为0表示未知，5xx 视为失败
+	StatusCode int
+	// Latency 请求耗时，为0时使用从选择到完成的时间
+	Latency time.Duration
+	// BytesSent 发送的字节数
+	BytesSent int64
+	// BytesReceived 接收的字节数
+	BytesReceived int64
+}
+
+// Success 请求是否成功，出错或状态码为 5xx 时视为失败
+func (r Result) Success() bool {
+	return r.Err == nil && r.StatusCode < 500
+}
+
+// GatewayError 是否为网关类错误（502、503、504），通常表示后端不可达或过载
+func (r Result) GatewayError() bool {
+	return r.StatusCode >= 502 && r.StatusCode <= 504
+}
+
+// DoneFunc 请求完成回调
+type DoneFunc func(server *Server, result Result)
+
+// Selection 一次服务器选择的句柄，请求结束后应调用 Done 回报结果
+type Selection struct {
+	// Server 被选中的服务器
+	Server *Server
+
+	start    time.Time
+	done     DoneFunc
+	finished atomic.Bool
+}
+
+// Done 回报请求结果，重复调用只有第一次生效
+func (s *Selection) Done(result Result) {
+	if !s.finished.CompareAndSwap(false, true) {
+		return
+	}
+	if result.Latency == 0 {
+		result.Latency = time.Since(s.start)
+	}
+	if s.done != nil {
+		s.done(s.Server, result)
+	}
+}
+
+// OnDone 注册请求完成回调，每次 Selection.Done 时都会调用，可用于指标统计等
+func (b *BaseLoadBalancer) OnDone(fn DoneFunc) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var hooks []DoneFunc
+	if current := b.doneHooks.Load(); current != nil {
+		hooks = slices.Clone(*current)
+	}
+	hooks = append(hooks, fn)
+	b.doneHooks.Store(&hooks)
+}
+
+// pickMaxAttempts 选中的服务器被熔断器拒绝时的最大选择次数
+const pickMaxAttempts = 3
+
+// pick 使用具体算法的 get 选择服务器并包装成 Selection，
+// release 为算法自身在请求完成时需要做的处理（如释放连接），可以为nil。
+// 选中的服务器被熔断器拒绝时会以 ErrCircuitOpen 调用 release 撤销本次选择，然后重新选择
+func (b *BaseLoadBalancer) pick(ctx context.Context, key string, get func(key string) *Server, release DoneFunc) (*Selection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var server *Server
+	var breaker *CircuitBreaker
+	var generation uint64
+	for attempt := 0; ; attempt++ {
+		server = get(key)
+		if server == nil {
+			return nil, b.unavailableError()
+		}
+
+		breaker = server.CircuitBreaker()
+		if breaker == nil {
+			break
+		}
+		var ok bool
+		if generation, ok = breaker.allow(); ok {
+			break
+		}
+		// 并发选择时半开状态的探测名额可能已被占满
+		if release != nil {
+			release(server, Result{Err: ErrCircuitOpen})
+		}
+		if attempt+1 >= pickMaxAttempts {
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	return &Selection{
+		Server: server,
+		start:  time.Now(),
+		done: func(server *Server, result Result) {
+			if breaker != nil {
+				breaker.done(generation, result.Success())
+			}
+			if release != nil {
+				release(server, result)
+			}
+			if hooks := b.doneHooks.Load(); hooks != nil {
+				for _, hook := range *hooks {
+					hook(server, result)
+				}
+			}
+		},
+	}, nil
+}
+
+// unavailableError 没有选出服务器时的错误：如果有服务器仅因熔断器而不可用，返回 ErrCircuitOpen
+func (b *BaseLoadBalancer) unavailableError() error {
+	for _, server := range b.GetServers() {
+		if server.Weight > 0 && server.Healthy() && !server.Ejected() && server.circuitBlocked() {
+			return ErrCircuitOpen
+		}
+	}
+	return ErrNoAvailableServer
+}
@@ -0,0 +1,61 @@
+package loadbalancer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLeastConnectionsPickDone(t *testing.T) {
+	lb := NewLeastConnectionsLoadBalancer(false)
+	server := &Server{Address: "Server-A", Weight: 1}
+	lb.AddServer(server)
+
+	var results []Result
+	lb.OnDone(func(s *Server, result Result) {
+		if s != server {
+			t.Errorf("回调的服务器不正确: %v", s)
+		}
+		results = append(results, result)
+	})
+
+	sel, err := lb.Pick(context.Background(), "")
+	if err != nil {
+		t.Fatalf("Pick 失败: %v", err)
+	}
+	if sel.Server != server || server.CurrentConnections != 1 {
+		t.Fatalf("选择结果不正确: %v, 连接数: %d", sel.Server, server.CurrentConnections)
+	}
+
+	// 重复调用 Done 只应释放一次连接
+	sel.Done(Result{Err: errors.New("boom")})
+	sel.Done(Result{})
+	if server.CurrentConnections != 0 {
+		t.Errorf("Done 后连接数应为0，实际: %d", server.CurrentConnections)
+	}
+	if len(results) != 1 || results[0].Success() {
+		t.Errorf("回调结果不正确: %+v", results)
+	}
+}
+
+func TestPickNoAvailableServer(t *testing.T) {
+	lbs := []LoadBalancer{
+		NewRandomLoadBalancer(),
+		NewRoundRobinLoadBalancer(true),
+		NewLeastConnectionsLoadBalancer(true),
+		NewMaglevHashLoadBalancer(),
+	}
+	for _, lb := range lbs {
+		if _, err := lb.Pick(context.Background(), "key"); !errors.Is(err, ErrNoAvailableServer) {
+			t.Errorf("%T: 期望 ErrNoAvailableServer，实际: %v", lb, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	lb := NewRandomLoadBalancer()
+	lb.AddServer(&Server{Address: "Server-A", Weight: 1})
+	if _, err := lb.Pick(ctx, ""); !errors.Is(err, context.Canceled) {
+		t.Errorf("期望 context.Canceled，实际: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package loadbalancer
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// ServerStatus 服务器状态，与权重相互独立：权重表示容量，状态表示是否接收新请求
+type ServerStatus int32
+
+const (
+	// StatusHealthy 健康，正常接收请求，新建的服务器默认处于该状态
+	StatusHealthy ServerStatus = iota
+	// StatusUnhealthy 不健康，通常由健康检查标记，恢复后自动回到 StatusHealthy
+	StatusUnhealthy
+	// StatusDraining 摘除中，不再接收新请求，已选中的请求正常完成
+	StatusDraining
+	// StatusDisabled 人工下线，不接收请求
+	StatusDisabled
+)
+
+// String 返回状态名称
+func (s ServerStatus) String() string {
+	switch s {
+	case StatusHealthy:
+		return "healthy"
+	case StatusUnhealthy:
+		return "unhealthy"
+	case StatusDraining:
+		return "draining"
+	case StatusDisabled:
+		return "disabled"
+	}
+	return "ServerStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// StatusChangeFunc 服务器状态变化回调
+type StatusChangeFunc func(server *Server, from, to ServerStatus)
+
+// statusEpoch 服务器状态的全局版本号，任意服务器的状态变化时递增，
+// 快照据此判断预先计算的可用服务器列表是否已经过期
+var statusEpoch atomic.Uint64
+
+// Status 获取服务器当前状态
+func (s *Server) Status() ServerStatus {
+	return ServerStatus(s.status.Load())
+}
+
+// SetStatus 设置服务器状态，可以并发调用，返回设置前的状态。
+// 状态发生变化时在当前 goroutine 中同步调用 OnStatusChange 注册的回调
+func (s *Server) SetStatus(status ServerStatus) ServerStatus {
+	old := ServerStatus(s.status.Swap(int32(status)))
+	if old != status {
+		s.statusChanged(old, status)
+	}
+	return old
+}
+
+// Healthy 服务器状态是否为 StatusHealthy
+func (s *Server) Healthy() bool {
+	return s.Status() == StatusHealthy
+}
+
+// SetHealthy 根据健康检查结果在 StatusHealthy 和 StatusUnhealthy 之间切换，可以并发调用。
+// 处于 StatusDraining 或 StatusDisabled 的服务器保持原状态，健康检查不会覆盖人工设置的状态
+func (s *Server) SetHealthy(healthy bool) {
+	from, to := StatusHealthy, StatusUnhealthy
+	if healthy {
+		from, to = to, from
+	}
+	if s.status.CompareAndSwap(int32(from), int32(to)) {
+		s.statusChanged(from, to)
+	}
+}
+
+// Ejected 服务器是否被任一异常检测器暂时驱逐，驱逐与 Status 相互独立，到期后自动恢复。
+// 同一台服务器加入多个负载均衡器时，每个异常检测器只会恢复自己驱逐的服务器
+func (s *Server) Ejected() bool {
+	return s.ejections.Load() > 0
+}
+
+// eject 记录一个异常检测器的驱逐，从未驱逐变为驱逐时使快照失效
+func (s *Server) eject() {
+	if s.ejections.Add(1) == 1 {
+		statusEpoch.Add(1)
+	}
+}
+
+// restore 撤销一个异常检测器的驱逐，所有驱逐都撤销时使快照失效
+func (s *Server) restore() {
+	if s.ejections.Add(-1) == 0 {
+		statusEpoch.Add(1)
+	}
+}
+
+// OnStatusChange 注册状态变化回调，回调在修改状态的 goroutine 中同步调用，不应阻塞
+func (s *Server) OnStatusChange(fn StatusChangeFunc) {
+	for {
+		current := s.statusHooks.Load()
+		var hooks []StatusChangeFunc
+		if current != nil {
+			hooks = append(hooks, *current...)
+		}
+		hooks = append(hooks, fn)
+		if s.statusHooks.CompareAndSwap(current, &hooks) {
+			return
+		}
+	}
+}
+
+// statusChanged 使快照失效并通知回调
+func (s *Server) statusChanged(from, to ServerStatus) {
+	statusEpoch.Add(1)
+	if hooks := s.statusHooks.Load(); hooks != nil {
+		for _, hook := range *hooks {
+			hook(s, from, to)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package loadbalancer
+
+import (
+	"context"
+	"testing"
+)
+
+// TestServerStatusTransitions 验证状态设置、健康检查不覆盖人工状态以及变化通知
+func TestServerStatusTransitions(t *testing.T) {
+	server := &Server{Address: "server1:8080", Weight: 2}
+	type change struct{ from, to ServerStatus }
+	var changes []change
+	server.OnStatusChange(func(s *Server, from, to ServerStatus) {
+		if s != server {
+			t.Errorf("回调收到的服务器应为 %s，实际: %s", server.Address, s.Address)
+		}
+		changes = append(changes, change{from, to})
+	})
+
+	if server.Status() != StatusHealthy {
+		t.Fatalf("新服务器的状态应为 healthy，实际: %v", server.Status())
+	}
+
+	server.SetHealthy(false)
+	server.SetHealthy(false)
+	if old := server.SetStatus(StatusDraining); old != StatusUnhealthy {
+		t.Errorf("SetStatus 应返回 unhealthy，实际: %v", old)
+	}
+	// 健康检查不能让摘除中的服务器重新接收流量
+	server.SetHealthy(true)
+	if server.Status() != StatusDraining {
+		t.Errorf("SetHealthy(true) 后状态应为 draining，实际: %v", server.Status())
+	}
+	server.SetStatus(StatusDisabled)
+	server.SetStatus(StatusHealthy)
+
+	want := []change{
+		{StatusHealthy, StatusUnhealthy},
+		{StatusUnhealthy, StatusDraining},
+		{StatusDraining, StatusDisabled},
+		{StatusDisabled, StatusHealthy},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("状态变化通知应为 %v，实际: %v", want, changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("第%d次通知应为 %v -> %v，实际: %v -> %v", i+1, want[i].from, want[i].to, changes[i].from, changes[i].to)
+		}
+	}
+	if server.Weight != 2 {
+		t.Errorf("权重不应被修改，实际: %d", server.Weight)
+	}
+}
+
+// TestBalancersRespectServerStatus 验证所有算法都不会选择摘除中或已下线的服务器
+func TestBalancersRespectServerStatus(t *testing.T) {
+	servers := newTestServers(5)
+	keys := newTestKeys(1000)
+	for _, c := range newSelectorCases() {
+		c.update(servers)
+		servers[1].SetStatus(StatusDraining)
+		servers[3].SetStatus(StatusDisabled)
+
+		for _, key := range keys {
+			server := c.selector.GetServer(key)
+			if server == nil || server.Status() != StatusHealthy {
+				t.Fatalf("%s: key %q 应选中正常状态的服务器，实际: %v", c.name, key, server)
+			}
+			if c.release != nil {
+				c.release(server)
+			}
+		}
+
+		servers[1].SetStatus(StatusHealthy)
+		servers[3].SetStatus(StatusHealthy)
+	}
+}
+
+// TestDrainingServerCompletesInFlightRequests 验证摘除前选中的请求完成时仍会正确释放连接
+func TestDrainingServerCompletesInFlightRequests(t *testing.T) {
+	server := &Server{Address: "server1:8080", Weight: 1}
+	lb := NewLeastConnectionsLoadBalancer(false)
+	lb.AddServer(server)
+
+	sel, err := lb.Pick(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetStatus(StatusDraining)
+	if _, err := lb.Pick(context.Background(), ""); err != ErrNoAvailableServer {
+		t.Fatalf("摘除后 Pick 应返回 ErrNoAvailableServer，实际: %v", err)
+	}
+
+	sel.Done(Result{})
+	if got := lb.connections.load(server); got != 0 {
+		t.Errorf("Done 后连接数应为0，实际: %d", got)
+	}
+}
@@ -0,0 +1,157 @@
+package loadbalancer
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// defaultSlowStartMinWeightPercent 默认的慢启动最小权重比例
+const defaultSlowStartMinWeightPercent = 10
+
+// SlowStartConfig 慢启动配置（参考 Envoy 的 slow start）
+// 新加入的服务器在窗口内的有效权重为 Weight * max(MinWeightPercent/100, (t/Window)^(1/Aggression))，
+// t 为加入后经过的时间。负载均衡器第一次选择服务器之前加入的服务器视为初始成员（例如逐个 AddServer
+// 或一次 UpdateServers 构建的服务器列表），不做慢启动。服务器按 Identity 识别，替换同一身份的服务器不会重新慢启动
+type SlowStartConfig struct {
+	// Window 慢启动窗口，为0表示不启用
+	Window time.Duration
+	// Aggression 权重增长曲线的系数，为1时线性增长，大于1时前期增长更快，小于等于0时按1处理
+	Aggression float64
+	// MinWeightPercent 有效权重的最小百分比，小于等于0时使用默认值10
+	MinWeightPercent float64
+}
+
+// slowStart 跟踪处于慢启动窗口内的服务器，nil 表示未启用慢启动
+type slowStart struct {
+	window     time.Duration
+	aggression float64
+	minFactor  float64
+	// now 获取当前时间，测试时可以替换
+	now func() time.Time
+	// serving 是否已经选择过服务器，之前加入的服务器都是初始成员
+	serving atomic.Bool
+
+	// started 处于慢启动窗口内的服务器身份及其加入时间，只在服务器列表变更时整体替换
+	started atomic.Pointer[map[string]time.Time]
+	// until 所有服务器慢启动结束的时间（UnixNano），之后选择路径不再查询 started
+	until atomic.Int64
+}
+
+// newSlowStart 根据配置创建慢启动跟踪器，未启用时返回nil
+func newSlowStart(config SlowStartConfig) *slowStart {
+	if config.Window <= 0 {
+		return nil
+	}
+	s := &slowStart{
+		window:     config.Window,
+		aggression: config.Aggression,
+		minFactor:  config.MinWeightPercent / 100,
+		now:        time.Now,
+	}
+	if s.aggression <= 0 {
+		s.aggression = 1
+	}
+	if config.MinWeightPercent <= 0 {
+		s.minFactor = defaultSlowStartMinWeightPercent / 100.0
+	}
+	s.started.Store(&map[string]time.Time{})
+	return s
+}
+
+// sync 根据变更前后的服务器列表记录新加入服务器的加入时间，调用方需保证 sync 之间不会并发执行
+func (s *slowStart) sync(previous, servers []*Server) {
+	if s == nil {
+		return
+	}
+
+	now := s.now()
+	initial := !s.serving.Load()
+	old := *s.started.Load()
+	known := make(map[string]bool, len(previous))
+	for _, server := range previous {
+		known[server.Identity()] = true
+	}
+
+	started := make(map[string]time.Time)
+	var until int64
+	for _, server := range servers {
+		addedAt, ok := old[server.Identity()]
+		if !ok {
+			// 已有的服务器和初始成员不做慢启动
+			if known[server.Identity()] || initial {
+				continue
+			}
+			addedAt = now
+		}
+		end := addedAt.Add(s.window)
+		if !now.Before(end) {
+			continue
+		}
+		started[server.Identity()] = addedAt
+		until = max(until, end.UnixNano())
+	}
+	s.started.Store(&started)
+	s.until.Store(until)
+}
+
+// active 是否有服务器处于慢启动窗口内，同时返回当前时间。每次选择服务器时调用，此后加入的服务器才做慢启动
+func (s *slowStart) active() (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	if !s.serving.Load() {
+		s.serving.Store(true)
+	}
+	if s.until.Load() == 0 {
+		return time.Time{}, false
+	}
+	now := s.now()
+	return now, now.UnixNano() < s.until.Load()
+}
+
+// factor 获取服务器当前的权重比例，不在慢启动窗口内时为1
+func (s *slowStart) factor(server *Server, now time.Time) float64 {
+	addedAt, ok := (*s.started.Load())[server.Identity()]
+	if !ok {
+		return 1
+	}
+	progress := float64(now.Sub(addedAt)) / float64(s.window)
+	if progress >= 1 {
+		return 1
+	}
+	if progress < 0 {
+		progress = 0
+	}
+	return max(s.minFactor, math.Pow(progress, 1/s.aggression))
+}
+
+// weight 获取服务器在慢启动调整后的权重
+func (s *slowStart) weight(server *Server, now time.Time) float64 {
+	return float64(server.Weight) * s.factor(server, now)
+}
+
+// weightedServer 按慢启动调整后的权重随机选择一台服务器，跳过探测名额已满的半开服务器，都不允许时返回nil。
+// random 返回 [0, 1) 内的随机数，必须是并发安全的
+func (s *slowStart) weightedServer(servers []*Server, now time.Time, random func() float64) *Server {
+	total := 0.0
+	var last *Server
+	for _, server := range servers {
+		if !server.circuitBlocked() {
+			total += s.weight(server, now)
+			last = server
+		}
+	}
+
+	r := random() * total
+	for _, server := range servers {
+		if server.circuitBlocked() {
+			continue
+		}
+		r -= s.weight(server, now)
+		if r < 0 {
+			return server
+		}
+	}
+	return last
+}
@@ -0,0 +1,169 @@
+package loadbalancer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// TestSlowStartFactor 验证线性和 aggression 曲线下的权重比例
+func TestSlowStartFactor(t *testing.T) {
+	start := time.Unix(3600, 0)
+	existing := []*Server{{Address: "existing:8080", Weight: 10}}
+	server := &Server{Address: "server1:8080", Weight: 10}
+	cases := []struct {
+		config  SlowStartConfig
+		elapsed time.Duration
+		want    float64
+	}{
+		{SlowStartConfig{Window: 10 * time.Second}, 0, 0.1},
+		{SlowStartConfig{Window: 10 * time.Second}, 5 * time.Second, 0.5},
+		{SlowStartConfig{Window: 10 * time.Second, MinWeightPercent: 1}, 500 * time.Millisecond, 0.05},
+		{SlowStartConfig{Window: 10 * time.Second, Aggression: 2}, 2500 * time.Millisecond, 0.5},
+		{SlowStartConfig{Window: 10 * time.Second}, 10 * time.Second, 1},
+	}
+	for _, c := range cases {
+		s := newSlowStart(c.config)
+		now := start
+		s.now = func() time.Time { return now }
+		s.sync(nil, existing)
+		// 开始选择后加入 server
+		s.active()
+		s.sync(existing, append(existing, server))
+
+		now = start.Add(c.elapsed)
+		if got := s.factor(server, now); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%+v 加入%v后的权重比例为 %v，期望为 %v", c.config, c.elapsed, got, c.want)
+		}
+	}
+
+	if newSlowStart(SlowStartConfig{}) != nil {
+		t.Error("没有设置窗口时启用了慢启动")
+	}
+}
+
+// slowStartCase 启用慢启动的负载均衡器测试用例
+type slowStartCase struct {
+	name      string
+	lb        LoadBalancer
+	slowStart *slowStart
+	// loadBased 按连接数选择的算法在窗口结束后会先补齐新服务器的连接数，按累计比例检查
+	loadBased bool
+}
+
+// newSlowStartCases 创建启用慢启动的各算法负载均衡器
+func newSlowStartCases(config SlowStartConfig) []slowStartCase {
+	random := NewRandomLoadBalancer(WithRandomSeed(1), WithRandomSlowStart(config))
+	roundRobin := NewRoundRobinLoadBalancer(true, WithRoundRobinSlowStart(config))
+	leastConn := NewLeastConnectionsLoadBalancer(true, WithLeastConnectionsSlowStart(config))
+	p2c := NewPowerOfTwoChoicesLoadBalancer(WithP2CSeed(1), WithP2CSlowStart(config))
+	return []slowStartCase{
+		{"Random", random, random.slowStart, false},
+		{"WeightedRoundRobin", roundRobin, roundRobin.slowStart, false},
+		{"WeightedLeastConnections", leastConn, leastConn.slowStart, true},
+		{"PowerOfTwoChoices", p2c, p2c.slowStart, true},
+	}
+}
+
+// TestSlowStartRampsNewServers 验证各算法在慢启动窗口内减少新服务器的流量，窗口结束后恢复正常比例
+func TestSlowStartRampsNewServers(t *testing.T) {
+	config := SlowStartConfig{Window: 10 * time.Second}
+	for _, c := range newSlowStartCases(config) {
+		now := time.Unix(0, 0)
+		c.slowStart.now = func() time.Time { return now }
+
+		// 初始成员不做慢启动
+		c.lb.UpdateServers([]*Server{
+			{Address: "server1:8080", Weight: 2},
+			{Address: "server2:8080", Weight: 2},
+		})
+		if _, ok := c.slowStart.active(); ok {
+			t.Fatalf("%s: 初始成员处于慢启动", c.name)
+		}
+
+		added := &Server{Address: "new:8080", Weight: 2}
+		c.lb.AddServer(added)
+
+		// 加入1秒后权重比例为10%，新服务器约占 0.1/2.1
+		now = now.Add(time.Second)
+		ramping := selectionCount(c.lb, added, 3000)
+		if share := float64(ramping) / 3000; share > 0.1 {
+			t.Errorf("%s: 慢启动期间新服务器的选择比例为 %.3f，期望低于 0.1", c.name, share)
+		}
+
+		// 窗口结束后三台服务器平分流量
+		now = now.Add(10 * time.Second)
+		if _, ok := c.slowStart.active(); ok {
+			t.Fatalf("%s: 窗口结束后仍处于慢启动", c.name)
+		}
+		steady := selectionCount(c.lb, added, 3000)
+		share := float64(steady) / 3000
+		if c.loadBased {
+			share = float64(ramping+steady) / 6000
+		}
+		if share < 0.25 || share > 0.42 {
+			t.Errorf("%s: 慢启动结束后新服务器的选择比例为 %.3f，期望约为 0.33", c.name, share)
+		}
+	}
+}
+
+// TestSlowStartInitialMembership 验证开始选择前逐个 AddServer 加入的服务器都不做慢启动，
+// 开始选择后加入的服务器无论距离第一次加入多久都做慢启动，替换同一身份的服务器不会重新慢启动
+func TestSlowStartInitialMembership(t *testing.T) {
+	config := SlowStartConfig{Window: time.Minute}
+	for _, c := range newSlowStartCases(config) {
+		now := time.Unix(0, 0)
+		c.slowStart.now = func() time.Time { return now }
+
+		servers := []*Server{
+			{Address: "server1:8080", Weight: 1},
+			{Address: "server2:8080", Weight: 1},
+			{Address: "server3:8080", Weight: 1},
+		}
+		for _, server := range servers {
+			now = now.Add(time.Second)
+			c.lb.AddServer(server)
+		}
+		if _, ok := c.slowStart.active(); ok {
+			t.Fatalf("%s: 逐个加入的初始成员处于慢启动", c.name)
+		}
+		for _, server := range servers {
+			if share := float64(selectionCount(c.lb, server, 3000)) / 3000; share < 0.25 || share > 0.42 {
+				t.Errorf("%s: %s 的选择比例为 %.3f，期望约为 0.33", c.name, server.Address, share)
+			}
+		}
+
+		// 距离第一次加入30秒，仍在一个窗口内
+		now = time.Unix(30, 0)
+		c.lb.AddServer(&Server{Address: "new:8080", Weight: 1})
+		if _, ok := c.slowStart.active(); !ok {
+			t.Fatalf("%s: 开始选择后加入的服务器没有慢启动", c.name)
+		}
+		if factor := c.slowStart.factor(&Server{Address: "new:8080"}, now); factor != c.slowStart.minFactor {
+			t.Errorf("%s: 新服务器的权重比例为 %v，期望为 %v", c.name, factor, c.slowStart.minFactor)
+		}
+
+		// 替换已有服务器和处于慢启动的服务器都保留原来的状态
+		now = now.Add(30 * time.Second)
+		replaced := &Server{Address: "server1:8080", Weight: 6}
+		c.lb.UpdateServer(replaced)
+		c.lb.UpdateServer(&Server{Address: "new:8080", Weight: 2})
+		if factor := c.slowStart.factor(replaced, now); factor != 1 {
+			t.Errorf("%s: 替换后的已有服务器权重比例为 %v，期望为 1", c.name, factor)
+		}
+		if factor := c.slowStart.factor(&Server{Address: "new:8080"}, now); math.Abs(factor-0.5) > 1e-9 {
+			t.Errorf("%s: 替换后的新服务器权重比例为 %v，期望为 0.5", c.name, factor)
+		}
+	}
+}
+
+// selectionCount 连续选择 n 次（不释放连接）并返回 target 被选中的次数
+func selectionCount(lb LoadBalancer, target *Server, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if lb.GetServer("") == target {
+			count++
+		}
+	}
+	return count
+}
@@ -41,11 +41,11 @@ func main() {
 	fmt.Println("\n测试加权轮询算法:")
 	weightedRoundRobinLB := loadbalancer.NewRoundRobinLoadBalancer(true) // 加权轮询
 
-	// 创建新的服务器数组，确保CurrentWeight和EffectiveWeight正确初始化
+	// 调度状态保存在负载均衡器内部，服务器只需要配置权重
 	wrrServers := []*loadbalancer.Server{
-		{Address: "192.168.1.3:8080", Weight: 3, EffectiveWeight: 3, CurrentWeight: 0}, // 权重3, 服务器A
-		{Address: "192.168.1.2:8080", Weight: 2, EffectiveWeight: 2, CurrentWeight: 0}, // 权重2, 服务器B
-		{Address: "192.168.1.1:8080", Weight: 1, EffectiveWeight: 1, CurrentWeight: 0}, // 权重1, 服务器C
+		{Address: "192.168.1.3:8080", Weight: 3}, // 权重3, 服务器A
+		{Address: "192.168.1.2:8080", Weight: 2}, // 权重2, 服务器B
+		{Address: "192.168.1.1:8080", Weight: 1}, // 权重1, 服务器C
 	}
 
 	for _, server := range wrrServers {
@@ -55,7 +55,8 @@ func main() {
 	fmt.Println("预期序列: [3权重, 2权重, 3权重, 1权重, 2权重, 3权重] - 平滑加权轮询")
 	for i := 0; i < 10; i++ {
 		server := weightedRoundRobinLB.GetServer("")
-		fmt.Printf("第%d次选择: %s (权重: %d, 当前权重: %d)\n", i+1, server.Address, server.Weight, server.CurrentWeight)
+		currentWeight, _, _ := weightedRoundRobinLB.Weights(server)
+		fmt.Printf("第%d次选择: %s (权重: %d, 当前权重: %d)\n", i+1, server.Address, server.Weight, currentWeight)
 	}
 	weightedRoundRobinLB.ResetWeights() // 重置权重
 
@@ -193,7 +194,7 @@ func main() {
 	// 在另一个场景测试：删除一个服务器，然后再测试键分布
 	fmt.Println("\n移除服务器后的一致性哈希测试:")
 	// 移除中间权重的服务器
-	maglevHashLB.RemoveServer(servers[1]) // 移除权重为2的服务器
+	maglevHashLB.RemoveServerByPointer(servers[1]) // 移除权重为2的服务器
 
 	// 记录移除服务器前的映射，检查变化
 	fmt.Println("移除服务器后的键映射变化:")